@@ -1,14 +1,21 @@
 package shell
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	chunker "github.com/ipfs/boxo/chunker"
 	files "github.com/ipfs/boxo/files"
+	mh "github.com/multiformats/go-multihash"
 )
 
 type object struct {
@@ -48,6 +55,9 @@ func RawLeaves(enabled bool) AddOpts {
 // Hash allows for selecting the multihash type
 func Hash(hash string) AddOpts {
 	return func(rb *RequestBuilder) error {
+		if _, ok := mh.Names[strings.ToLower(hash)]; !ok {
+			return fmt.Errorf("unrecognized hash function: %q", strings.ToLower(hash))
+		}
 		rb.Option("hash", hash)
 		return nil
 	}
@@ -56,27 +66,226 @@ func Hash(hash string) AddOpts {
 // CidVersion allows for selecting the CID version that ipfs should use.
 func CidVersion(version int) AddOpts {
 	return func(rb *RequestBuilder) error {
+		if version != 0 && version != 1 {
+			return fmt.Errorf("unknown CID version: %d", version)
+		}
 		rb.Option("cid-version", version)
 		return nil
 	}
 }
 
+// Chunker selects the chunking algorithm: "size-<bytes>", "rabin",
+// "rabin-<avg>", "rabin-<min>-<avg>-<max>" or "buzhash". Chunks can't be
+// larger than 1MiB.
+func Chunker(spec string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if _, err := chunker.FromString(bytes.NewReader(nil), spec); err != nil {
+			return fmt.Errorf("invalid chunker %q: %w", spec, err)
+		}
+		rb.Option("chunker", spec)
+		return nil
+	}
+}
+
+// ChunkerSize splits files into chunks of the given size.
+func ChunkerSize(size int) AddOpts {
+	return Chunker("size-" + strconv.Itoa(size))
+}
+
+// ChunkerRabin splits files with Rabin fingerprinting, into chunks of the
+// given minimum, average and maximum sizes.
+func ChunkerRabin(min, avg, max int) AddOpts {
+	return Chunker(fmt.Sprintf("rabin-%d-%d-%d", min, avg, max))
+}
+
+// ChunkerBuzhash splits files with the buzhash rolling hash.
+func ChunkerBuzhash() AddOpts {
+	return Chunker("buzhash")
+}
+
+// Trickle selects the trickle DAG layout, optimized for sequential reads,
+// instead of the balanced one.
+func Trickle(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("trickle", enabled)
+		return nil
+	}
+}
+
+// Inline inlines blocks no larger than the inline limit into their CIDs,
+// using the identity hash.
+func Inline(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("inline", enabled)
+		return nil
+	}
+}
+
+// InlineLimit sets the maximum size of inlined blocks. Default 32.
+func InlineLimit(limit int) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if limit <= 0 {
+			return fmt.Errorf("inline limit must be positive, got %d", limit)
+		}
+		rb.Option("inline-limit", limit)
+		return nil
+	}
+}
+
+// NoCopy adds files by reference using the filestore, instead of copying
+// their data into the blockstore. It implies raw leaves, and requires the
+// filestore to be enabled on the daemon, which needs to be able to read the
+// files at the same paths.
+func NoCopy(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("nocopy", enabled)
+		return nil
+	}
+}
+
+// FsCache checks the filestore for pre-existing blocks.
+func FsCache(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("fscache", enabled)
+		return nil
+	}
+}
+
+// WrapWithDirectory wraps what is added into a directory, so the name of the
+// file or directory is kept.
+func WrapWithDirectory(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("wrap-with-directory", enabled)
+		return nil
+	}
+}
+
+// ToFiles adds a reference to the result to the MFS at the given absolute
+// path. A path with a trailing slash names the directory to add it to.
+func ToFiles(path string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("to-files: paths must start with a leading slash, got %q", path)
+		}
+		rb.Option("to-files", path)
+		return nil
+	}
+}
+
+// PreserveMode stores the permissions of added files in the UnixFS metadata.
+// Daemons that predate this option ignore it.
+func PreserveMode(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("preserve-mode", enabled)
+		return nil
+	}
+}
+
+// PreserveMtime stores the modification time of added files in the UnixFS
+// metadata. Daemons that predate this option ignore it.
+func PreserveMtime(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option("preserve-mtime", enabled)
+		return nil
+	}
+}
+
+// Mode stores the given permissions in the UnixFS metadata of the added root.
+// Daemons that predate this option ignore it.
+func Mode(mode os.FileMode) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if mode&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != 0 {
+			return fmt.Errorf("mode %s has bits other than permissions set", mode)
+		}
+		// UnixFS uses the POSIX bit layout, which differs from Go's for the
+		// special bits.
+		posix := uint32(mode.Perm())
+		if mode&os.ModeSetuid != 0 {
+			posix |= 0o4000
+		}
+		if mode&os.ModeSetgid != 0 {
+			posix |= 0o2000
+		}
+		if mode&os.ModeSticky != 0 {
+			posix |= 0o1000
+		}
+		rb.Option("mode", posix)
+		return nil
+	}
+}
+
+// Mtime stores the given modification time in the UnixFS metadata of the
+// added root. Daemons that predate this option ignore it.
+func Mtime(mtime time.Time) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if mtime.IsZero() {
+			return errors.New("mtime must not be zero")
+		}
+		rb.Option("mtime", mtime.Unix())
+		if nsecs := mtime.Nanosecond(); nsecs != 0 {
+			rb.Option("mtime-nsecs", nsecs)
+		}
+		return nil
+	}
+}
+
+// addRequest creates an add request with the given options, see
+// applyAddOpts.
+func (s *Shell) addRequest(options []AddOpts) (*RequestBuilder, *dirFilter, error) {
+	rb := s.Request("add")
+	filter, err := applyAddOpts(rb, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rb, filter, nil
+}
+
+// applyAddOpts applies the options to an add request, checking their
+// combination like the daemon would. The client-side options are removed from
+// the request and returned as the filter to apply to directories.
+func applyAddOpts(rb *RequestBuilder, options []AddOpts) (*dirFilter, error) {
+	for _, option := range options {
+		if err := option(rb); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := rb.opts
+	if opts["nocopy"] == "true" && opts["raw-leaves"] == "false" {
+		return nil, errors.New("nocopy option requires raw leaves to be enabled as well")
+	}
+	if hash, ok := opts["hash"]; ok && strings.ToLower(hash) != "sha2-256" && opts["cid-version"] == "0" {
+		return nil, errors.New("CIDv0 only supports sha2-256")
+	}
+	if _, ok := opts["to-files"]; ok && opts["only-hash"] == "true" {
+		return nil, errors.New("only-hash and to-files options are not compatible")
+	}
+
+	return newDirFilter(opts)
+}
+
 // Add adds a file to ipfs pinning it with the given options
 func (s *Shell) Add(r io.Reader, options ...AddOpts) (string, error) {
+	return s.AddCtx(context.Background(), r, options...)
+}
+
+// AddCtx is like Add but with a context.
+func (s *Shell) AddCtx(ctx context.Context, r io.Reader, options ...AddOpts) (string, error) {
+	rb, _, err := s.addRequest(options)
+	if err != nil {
+		return "", err
+	}
+
 	fr := files.NewReaderFile(r)
 	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
 
-	fileReader, err := s.newMultiFileReader(slf)
+	fileReader, err := s.newMultiFileReader(ctx, slf)
 	if err != nil {
 		return "", err
 	}
 
 	var out object
-	rb := s.Request("add")
-	for _, option := range options {
-		option(rb)
-	}
-	return out.Hash, rb.Body(fileReader).Exec(context.Background(), &out)
+	return out.Hash, rb.Body(fileReader).Exec(ctx, &out)
 }
 
 // AddNoPin adds a file to ipfs without pinning it
@@ -92,45 +301,44 @@ func (s *Shell) AddWithOpts(r io.Reader, pin bool, rawLeaves bool) (string, erro
 }
 
 func (s *Shell) AddLink(target string) (string, error) {
+	return s.AddLinkCtx(context.Background(), target)
+}
+
+// AddLinkCtx is like AddLink but with a context.
+func (s *Shell) AddLinkCtx(ctx context.Context, target string) (string, error) {
 	link := files.NewLinkFile(target, nil)
 	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", link)})
 
-	reader, err := s.newMultiFileReader(slf)
+	reader, err := s.newMultiFileReader(ctx, slf)
 	if err != nil {
 		return "", err
 	}
 
 	var out object
-	return out.Hash, s.Request("add").Body(reader).Exec(context.Background(), &out)
+	return out.Hash, s.Request("add").Body(reader).Exec(ctx, &out)
 }
 
 // AddDir adds a directory recursively with all of the files under it
 func (s *Shell) AddDir(dir string, options ...AddOpts) (string, error) {
-	stat, err := os.Lstat(dir)
-	if err != nil {
-		return "", err
-	}
+	return s.AddDirCtx(context.Background(), dir, options...)
+}
 
-	sf, err := files.NewSerialFile(dir, false, stat)
+// AddDirCtx is like AddDir but with a context.
+func (s *Shell) AddDirCtx(ctx context.Context, dir string, options ...AddOpts) (string, error) {
+	rb, filter, err := s.addRequest(options)
 	if err != nil {
 		return "", err
 	}
-	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry(filepath.Base(dir), sf)})
 
-	reader, err := s.newMultiFileReader(slf)
+	reader, err := s.dirReader(ctx, dir, filter)
 	if err != nil {
 		return "", err
 	}
 
-	rb := s.Request("add").Option("recursive", true)
-	for _, option := range options {
-		option(rb)
-	}
-
 	// Here we cannot use .Exec because "add" streams responses back for each file
 	// within the directory, and we only care about the last one, which is the directory
 	// itself.
-	resp, err := rb.Body(reader).Send(context.Background())
+	resp, err := rb.Option("recursive", true).Body(reader).Send(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -160,3 +368,177 @@ func (s *Shell) AddDir(dir string, options ...AddOpts) (string, error) {
 
 	return final, nil
 }
+
+func (s *Shell) dirReader(ctx context.Context, dir string, filter *dirFilter) (*files.MultiFileReader, error) {
+	sf, err := filter.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry(filepath.Base(dir), sf)})
+
+	return s.newMultiFileReader(ctx, slf)
+}
+
+// AddEventType tells what an AddEvent reports.
+type AddEventType int
+
+const (
+	// AddProgress events report how many bytes of a file were added so far.
+	AddProgress AddEventType = iota
+	// AddEntry events report a file or directory that has been added.
+	AddEntry
+	// AddRoot is the type of the last event of a successful add, reporting
+	// the root of everything that was added.
+	AddRoot
+	// AddError is the type of the last event of a failed add, carrying the
+	// error in Err.
+	AddError
+)
+
+// AddEvent is an event of a streaming add. Consumers should check Err, or
+// equivalently a Type of AddError, before looking at the other fields.
+type AddEvent struct {
+	Type AddEventType
+	// Name is the path of the file or directory, relative to what was added.
+	Name string
+	// Hash and Size, the cumulative size of the DAG, are set on entries and
+	// on the root.
+	Hash string
+	Size uint64
+	// Bytes is the number of bytes of Name added so far, on progress events.
+	Bytes int64
+	// Err is set, with a Type of AddError and all other fields empty, on
+	// the last event of a failed add.
+	Err error
+}
+
+// addOutput is an event as streamed by the add command.
+type addOutput struct {
+	Name  string
+	Hash  string `json:",omitempty"`
+	Bytes int64  `json:",omitempty"`
+	Size  string `json:",omitempty"`
+}
+
+func (o *addOutput) event() (AddEvent, error) {
+	if o.Hash == "" {
+		return AddEvent{Type: AddProgress, Name: o.Name, Bytes: o.Bytes}, nil
+	}
+
+	ev := AddEvent{Type: AddEntry, Name: o.Name, Hash: o.Hash}
+	if o.Size != "" {
+		size, err := strconv.ParseUint(o.Size, 10, 64)
+		if err != nil {
+			return AddEvent{}, fmt.Errorf("invalid size of %s: %w", o.Name, err)
+		}
+		ev.Size = size
+	}
+	return ev, nil
+}
+
+// AddStream adds a file like Add, and returns the events of the add as they
+// are received. Progress events are enabled by default, pass Progress(false)
+// to only receive the entry and root events.
+//
+// The channel is closed after the root event, or after an event carrying the
+// error the add failed with. Cancelling ctx aborts the add and closes the
+// channel.
+func (s *Shell) AddStream(ctx context.Context, r io.Reader, options ...AddOpts) (<-chan AddEvent, error) {
+	rb, _, err := s.addRequest(append([]AddOpts{Progress(true)}, options...))
+	if err != nil {
+		return nil, err
+	}
+
+	fr := files.NewReaderFile(r)
+	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
+
+	fileReader, err := s.newMultiFileReader(ctx, slf)
+	if err != nil {
+		return nil, err
+	}
+
+	return addStream(ctx, rb.Body(fileReader))
+}
+
+// AddDirStream adds a directory like AddDir, and returns the events of the add
+// as they are received. There is an entry event for every file and directory
+// under dir, see AddStream.
+func (s *Shell) AddDirStream(ctx context.Context, dir string, options ...AddOpts) (<-chan AddEvent, error) {
+	rb, filter, err := s.addRequest(append([]AddOpts{Progress(true)}, options...))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := s.dirReader(ctx, dir, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return addStream(ctx, rb.Option("recursive", true).Body(reader))
+}
+
+func addStream(ctx context.Context, rb *RequestBuilder) (<-chan AddEvent, error) {
+	resp, err := rb.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	out := make(chan AddEvent)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+
+		send := func(ev AddEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Only the last entry is the root, so entries are held back until
+		// the next event arrives.
+		var last *AddEvent
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var o addOutput
+			err := dec.Decode(&o)
+			if err == io.EOF {
+				if last == nil {
+					send(AddEvent{Type: AddError, Err: errors.New("no results received")})
+					return
+				}
+				last.Type = AddRoot
+				send(*last)
+				return
+			}
+
+			var ev AddEvent
+			if err == nil {
+				ev, err = o.event()
+			}
+			if err != nil {
+				send(AddEvent{Type: AddError, Err: err})
+				return
+			}
+
+			if last != nil {
+				if !send(*last) {
+					return
+				}
+				last = nil
+			}
+			if ev.Type == AddEntry {
+				last = &ev
+			} else if !send(ev) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,429 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/crackcomm/go-gitignore"
+	files "github.com/ipfs/boxo/files"
+)
+
+// SymlinkPolicy tells how symlinks are handled when adding a directory.
+type SymlinkPolicy int
+
+const (
+	// PreserveSymlinks adds symlinks as symlinks. This is the default.
+	PreserveSymlinks SymlinkPolicy = iota
+	// FollowSymlinks adds the files and directories symlinks point to, under
+	// the name of the symlink.
+	FollowSymlinks
+	// SkipSymlinks leaves symlinks out.
+	SkipSymlinks
+)
+
+var symlinkPolicies = []string{"preserve", "follow", "skip"}
+
+func (p SymlinkPolicy) String() string {
+	if p < 0 || int(p) >= len(symlinkPolicies) {
+		return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+	}
+	return symlinkPolicies[p]
+}
+
+// Hidden includes hidden files when adding a directory. They are skipped by
+// default.
+func Hidden(enabled bool) AddOpts {
+	return func(rb *RequestBuilder) error {
+		rb.Option(addHiddenOption, enabled)
+		return nil
+	}
+}
+
+// Ignore skips files matching any of the given .gitignore-style rules when
+// adding a directory. Rules are relative to the added directory. It can be
+// passed several times.
+func Ignore(rules ...string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		return appendOption(rb, addIgnoreOption, rules)
+	}
+}
+
+// IgnoreRulesPath skips files matching the rules of the given .gitignore-style
+// file when adding a directory. Rules are relative to the added directory.
+func IgnoreRulesPath(path string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		rb.Option(addIgnoreRulesOption, path)
+		return nil
+	}
+}
+
+// IgnoreFiles reads .gitignore-style rules from the files with the given
+// names in every directory that is added, like git does with .gitignore
+// files: their rules apply to the directory they are in and below. For
+// instance:
+//
+//	sh.AddDir(dir, IgnoreFiles(".gitignore", ".ipfsignore"))
+func IgnoreFiles(names ...string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		for _, name := range names {
+			if name == "" || strings.ContainsAny(name, `/\`) {
+				return fmt.Errorf("invalid ignore file name %q", name)
+			}
+		}
+		return appendOption(rb, addIgnoreFilesOption, names)
+	}
+}
+
+// Include only adds the files matching any of the given globs when adding a
+// directory. Directories are always traversed. Globs use the syntax of
+// path.Match; globs without a slash match the file name, the others the path
+// relative to the added directory. It can be passed several times.
+func Include(globs ...string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if err := checkGlobs(globs); err != nil {
+			return err
+		}
+		return appendOption(rb, addIncludeOption, globs)
+	}
+}
+
+// Exclude skips the files and directories matching any of the given globs
+// when adding a directory. Globs are matched like with Include. It can be
+// passed several times.
+func Exclude(globs ...string) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if err := checkGlobs(globs); err != nil {
+			return err
+		}
+		return appendOption(rb, addExcludeOption, globs)
+	}
+}
+
+// Symlinks sets how symlinks are handled when adding a directory.
+func Symlinks(policy SymlinkPolicy) AddOpts {
+	return func(rb *RequestBuilder) error {
+		if policy < 0 || int(policy) >= len(symlinkPolicies) {
+			return fmt.Errorf("unknown symlink policy: %s", policy)
+		}
+		rb.Option(addSymlinksOption, policy.String())
+		return nil
+	}
+}
+
+// Options of add that only affect how directories are read by the client.
+// They are recorded on the request like the others, and removed before it is
+// sent.
+const (
+	addHiddenOption      = "hidden"
+	addIgnoreOption      = "ignore"
+	addIgnoreRulesOption = "ignore-rules-path"
+	addIgnoreFilesOption = "ignore-files"
+	addIncludeOption     = "include"
+	addExcludeOption     = "exclude"
+	addSymlinksOption    = "symlinks"
+)
+
+// appendOption adds values to a list option, stored one per line.
+func appendOption(rb *RequestBuilder, key string, values []string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("%s value %q spans several lines", key, v)
+		}
+	}
+	if prev, ok := rb.opts[key]; ok {
+		values = append([]string{prev}, values...)
+	}
+	rb.Option(key, strings.Join(values, "\n"))
+	return nil
+}
+
+func checkGlobs(globs []string) error {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// dirFilter selects what is read from disk when adding a directory.
+type dirFilter struct {
+	hidden      *files.Filter
+	rules       *ignore.GitIgnore
+	ignoreFiles []string
+	include     []string
+	exclude     []string
+	symlinks    SymlinkPolicy
+}
+
+// newDirFilter builds the filter from the client-side options of an add
+// request, and removes them from it.
+func newDirFilter(opts map[string]string) (*dirFilter, error) {
+	list := func(key string) []string {
+		v, ok := opts[key]
+		delete(opts, key)
+		if !ok || v == "" {
+			return nil
+		}
+		return strings.Split(v, "\n")
+	}
+
+	hidden, err := files.NewFilter("", nil, opts[addHiddenOption] == "true")
+	if err != nil {
+		return nil, err
+	}
+	delete(opts, addHiddenOption)
+
+	rules := list(addIgnoreOption)
+	var gi *ignore.GitIgnore
+	if rulesPath := opts[addIgnoreRulesOption]; rulesPath != "" {
+		gi, err = ignore.CompileIgnoreFileAndLines(rulesPath, rules...)
+	} else {
+		gi, err = ignore.CompileIgnoreLines(rules...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	delete(opts, addIgnoreRulesOption)
+
+	f := &dirFilter{
+		hidden:      hidden,
+		rules:       gi,
+		ignoreFiles: list(addIgnoreFilesOption),
+		include:     list(addIncludeOption),
+		exclude:     list(addExcludeOption),
+	}
+	if policy, ok := opts[addSymlinksOption]; ok {
+		for i, name := range symlinkPolicies {
+			if name == policy {
+				f.symlinks = SymlinkPolicy(i)
+			}
+		}
+		delete(opts, addSymlinksOption)
+	}
+	return f, nil
+}
+
+// open returns the file, directory or symlink at p, filtered.
+func (f *dirFilter) open(p string) (files.Node, error) {
+	stat, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode()&os.ModeSymlink != 0 && f.symlinks == FollowSymlinks {
+		if stat, err = os.Stat(p); err != nil {
+			return nil, err
+		}
+	}
+	root := &filteredDir{filter: f, path: p, stat: stat}
+	return root.node(p, "", stat)
+}
+
+// scopedRules are the rules of an ignore file, relative to its directory.
+type scopedRules struct {
+	dir   string
+	rules *ignore.GitIgnore
+}
+
+// filteredDir is a directory on disk, listed through a dirFilter. Like the
+// directories of files.NewSerialFile, files are only opened while iterating.
+type filteredDir struct {
+	filter *dirFilter
+	path   string
+	rel    string
+	stat   os.FileInfo
+	rules  []scopedRules
+	// real paths of this directory and its parents, to detect cycles when
+	// following symlinks.
+	parents []string
+}
+
+type filteredEntry struct {
+	name string
+	path string
+	rel  string
+	stat os.FileInfo
+}
+
+func (d *filteredDir) node(p, rel string, stat os.FileInfo) (files.Node, error) {
+	if !stat.IsDir() {
+		return files.NewSerialFile(p, true, stat)
+	}
+
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range d.parents {
+		if parent == real {
+			return nil, fmt.Errorf("symlink cycle at %s", p)
+		}
+	}
+
+	sub := &filteredDir{
+		filter:  d.filter,
+		path:    p,
+		rel:     rel,
+		stat:    stat,
+		rules:   d.rules,
+		parents: append(d.parents[:len(d.parents):len(d.parents)], real),
+	}
+	for _, name := range d.filter.ignoreFiles {
+		rules, err := ignore.CompileIgnoreFile(filepath.Join(p, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sub.rules = append(sub.rules[:len(sub.rules):len(sub.rules)], scopedRules{rel, rules})
+	}
+	return sub, nil
+}
+
+// entries lists the directory, without opening anything.
+func (d *filteredDir) entries() ([]filteredEntry, error) {
+	des, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := d.filter
+	entries := make([]filteredEntry, 0, len(des))
+	for _, de := range des {
+		stat, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		if f.hidden.ShouldExclude(stat) {
+			continue
+		}
+
+		p := filepath.Join(d.path, de.Name())
+		if stat.Mode()&os.ModeSymlink != 0 {
+			switch f.symlinks {
+			case SkipSymlinks:
+				continue
+			case FollowSymlinks:
+				if stat, err = os.Stat(p); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		rel := path.Join(d.rel, de.Name())
+		if d.ignored(rel, stat.IsDir()) ||
+			matchGlobs(f.exclude, rel) ||
+			(!stat.IsDir() && f.include != nil && !matchGlobs(f.include, rel)) {
+			continue
+		}
+		entries = append(entries, filteredEntry{de.Name(), p, rel, stat})
+	}
+	return entries, nil
+}
+
+func (d *filteredDir) ignored(rel string, dir bool) bool {
+	if dir {
+		// lets rules with a trailing slash match
+		rel += "/"
+	}
+	if d.filter.rules.MatchesPath(rel) {
+		return true
+	}
+	for _, r := range d.rules {
+		if r.rules.MatchesPath(strings.TrimPrefix(rel, r.dir+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobs(globs []string, rel string) bool {
+	for _, glob := range globs {
+		name := rel
+		if !strings.Contains(glob, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *filteredDir) Entries() files.DirIterator {
+	entries, err := d.entries()
+	return &filteredIterator{dir: d, entries: entries, err: err}
+}
+
+func (d *filteredDir) Close() error {
+	return nil
+}
+
+// Size returns the total size of the files that would be added.
+func (d *filteredDir) Size() (int64, error) {
+	entries, err := d.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, e := range entries {
+		switch {
+		case e.stat.IsDir():
+			nd, err := d.node(e.path, e.rel, e.stat)
+			if err != nil {
+				return 0, err
+			}
+			s, err := nd.Size()
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		case e.stat.Mode().IsRegular():
+			size += e.stat.Size()
+		}
+	}
+	return size, nil
+}
+
+type filteredIterator struct {
+	dir     *filteredDir
+	entries []filteredEntry
+	cur     files.Node
+	err     error
+}
+
+func (it *filteredIterator) Name() string {
+	return it.entries[0].name
+}
+
+func (it *filteredIterator) Node() files.Node {
+	return it.cur
+}
+
+func (it *filteredIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.cur != nil {
+		it.entries = it.entries[1:]
+	}
+	if len(it.entries) == 0 {
+		return false
+	}
+
+	e := it.entries[0]
+	it.cur, it.err = it.dir.node(e.path, e.rel, e.stat)
+	return it.err == nil
+}
+
+func (it *filteredIterator) Err() error {
+	return it.err
+}
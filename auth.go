@@ -0,0 +1,144 @@
+package shell
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	authSecretBasic  = "basic:"
+	authSecretBearer = "bearer:"
+)
+
+// WithBasicAuth authenticates every request with the given basic credentials.
+func WithBasicAuth(username, password string) ShellOption {
+	return func(s *Shell) {
+		s.auth = basicAuth(username, password)
+	}
+}
+
+// WithBearerToken authenticates every request with the given bearer token.
+func WithBearerToken(token string) ShellOption {
+	return func(s *Shell) {
+		s.auth = "Bearer " + token
+	}
+}
+
+// WithAuthSecret authenticates every request with a secret in the format of
+// Kubo's API.Authorizations AuthSecret and --api-auth flag: "basic:user:pass",
+// "basic:<base64 of user:pass>" or "bearer:token". A secret without a type is
+// a bearer token.
+func WithAuthSecret(secret string) ShellOption {
+	return func(s *Shell) {
+		s.auth = authHeader(secret)
+	}
+}
+
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// authHeader converts a Kubo AuthSecret into an Authorization header value,
+// the same way the daemon does when checking requests.
+func authHeader(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case strings.HasPrefix(secret, authSecretBearer):
+		return "Bearer " + strings.TrimPrefix(secret, authSecretBearer)
+	case strings.HasPrefix(secret, authSecretBasic):
+		userpass := strings.TrimPrefix(secret, authSecretBasic)
+		if username, password, ok := strings.Cut(userpass, ":"); ok {
+			return basicAuth(username, password)
+		}
+		return "Basic " + userpass
+	case strings.Contains(secret, ":"):
+		// unknown type
+		return ""
+	default:
+		return "Bearer " + secret
+	}
+}
+
+// splitCredentials strips the credentials embedded in a daemon address and
+// returns them as an Authorization header value.
+func splitCredentials(addr string) (string, string) {
+	if !strings.Contains(addr, "@") {
+		return addr, ""
+	}
+
+	// multiaddr, with credentials prepended as in user:pass@/ip4/...
+	if i := strings.Index(addr, "@/"); i >= 0 && !strings.Contains(addr[:i], "/") {
+		return addr[i+1:], userinfoAuth(addr[:i])
+	}
+
+	raw := addr
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return addr, ""
+	}
+	auth := userinfoAuth(u.User.String())
+	u.User = nil
+	if raw != addr {
+		return strings.TrimPrefix(u.String(), "http://"), auth
+	}
+	return u.String(), auth
+}
+
+func userinfoAuth(userinfo string) string {
+	u, err := url.Parse("http://" + userinfo + "@host")
+	if err != nil || u.User == nil {
+		return ""
+	}
+	username := u.User.Username()
+	if password, ok := u.User.Password(); ok {
+		return basicAuth(username, password)
+	}
+	return "Bearer " + username
+}
+
+// authSecretFromConfig returns the AuthSecret of the first API authorization,
+// sorted by name, that grants access to the whole RPC API. Errors reading the
+// config are ignored: the daemon may run as another user, in which case the
+// caller has to provide credentials.
+func authSecretFromConfig(configFile string) string {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return ""
+	}
+
+	var cfg struct {
+		API struct {
+			Authorizations map[string]struct {
+				AuthSecret   string
+				AllowedPaths []string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(cfg.API.Authorizations))
+	for name := range cfg.API.Authorizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		auth := cfg.API.Authorizations[name]
+		for _, p := range auth.AllowedPaths {
+			if strings.HasPrefix("/api/v0", strings.TrimSuffix(p, "/")) {
+				return auth.AuthSecret
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+)
+
+// authServer answers every request with the Authorization header it received.
+func authServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%q", r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func receivedAuth(t *testing.T, s *Shell) string {
+	var auth string
+	if err := s.Request("version").Exec(context.Background(), &auth); err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestAuthOptions(t *testing.T) {
+	is := is.New(t)
+	srv := authServer(t)
+
+	is.Equal(receivedAuth(t, NewShell(srv.URL)), "")
+	is.Equal(receivedAuth(t, NewShell(srv.URL, WithBasicAuth("alice", "secret"))), "Basic YWxpY2U6c2VjcmV0")
+	is.Equal(receivedAuth(t, NewShell(srv.URL, WithBearerToken("token"))), "Bearer token")
+
+	for secret, expected := range map[string]string{
+		"basic:alice:secret":       "Basic YWxpY2U6c2VjcmV0",
+		"basic:YWxpY2U6c2VjcmV0":   "Basic YWxpY2U6c2VjcmV0",
+		"bearer:token":             "Bearer token",
+		"token":                    "Bearer token",
+		"unknown:type":             "",
+		"bearer:token:with:colons": "Bearer token:with:colons",
+	} {
+		is.Equal(receivedAuth(t, NewShell(srv.URL, WithAuthSecret(secret))), expected)
+	}
+
+	// explicit headers win
+	s := NewShell(srv.URL, WithBearerToken("token"))
+	var auth string
+	err := s.Request("version").Header("Authorization", "Bearer other").Exec(context.Background(), &auth)
+	is.Nil(err)
+	is.Equal(auth, "Bearer other")
+}
+
+func TestAuthFromAddress(t *testing.T) {
+	is := is.New(t)
+	srv := authServer(t)
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	is.Nil(err)
+
+	for addr, expected := range map[string]string{
+		"http://alice:secret@" + host + ":" + port:      "Basic YWxpY2U6c2VjcmV0",
+		"alice:secret@" + host + ":" + port:             "Basic YWxpY2U6c2VjcmV0",
+		"token@" + host + ":" + port:                    "Bearer token",
+		"alice:secret@/ip4/" + host + "/tcp/" + port:    "Basic YWxpY2U6c2VjcmV0",
+		"token@/ip4/" + host + "/tcp/" + port:           "Bearer token",
+		"al%40ice:p%3Ass@/ip4/" + host + "/tcp/" + port: "Basic YWxAaWNlOnA6c3M=",
+		"/ip4/" + host + "/tcp/" + port:                 "",
+	} {
+		s := NewShell(addr)
+		is.Equal(receivedAuth(t, s), expected)
+		is.False(strings.Contains(s.url, "@"))
+	}
+
+	// options override the address
+	s := NewShell("alice:secret@/ip4/"+host+"/tcp/"+port, WithBearerToken("token"))
+	is.Equal(receivedAuth(t, s), "Bearer token")
+}
+
+func TestAuthFromConfig(t *testing.T) {
+	is := is.New(t)
+	srv := authServer(t)
+
+	dir := t.TempDir()
+	t.Setenv(EnvDir, dir)
+	is.Nil(os.WriteFile(filepath.Join(dir, DefaultApiFile), []byte(srv.URL+"\n"), 0o600))
+
+	s := NewLocalShell()
+	is.NotNil(s)
+	is.Equal(receivedAuth(t, s), "")
+
+	config := `{
+	"API": {
+		"Authorizations": {
+			"restricted": {"AuthSecret": "bearer:restricted", "AllowedPaths": ["/api/v0/id"]},
+			"zed": {"AuthSecret": "bearer:zed", "AllowedPaths": ["/api/v0"]},
+			"bob": {"AuthSecret": "basic:bob:secret", "AllowedPaths": ["/api/v0/"]}
+		}
+	}
+}`
+	is.Nil(os.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte(config), 0o600))
+
+	s = NewLocalShell()
+	is.NotNil(s)
+	is.Equal(receivedAuth(t, s), "Basic Ym9iOnNlY3JldA==")
+
+	s = NewLocalShell(WithBearerToken("token"))
+	is.Equal(receivedAuth(t, s), "Bearer token")
+}
@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestBlockPutWithOpts(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	key, err := s.BlockPutWithOpts([]byte("raw block"))
+	is.Nil(err)
+	c, err := cid.Decode(key)
+	is.Nil(err)
+	is.Equal(c.Version(), uint64(1))
+	is.Equal(c.Type(), uint64(cid.Raw))
+
+	// {"a": 1} as dag-cbor
+	key, err = s.BlockPutWithOptsCtx(ctx, []byte{0xa1, 0x61, 0x61, 0x01},
+		options.Block.CidCodec("dag-cbor"),
+		options.Block.Hash("sha2-512", -1),
+		options.Block.Pin(true))
+	is.Nil(err)
+	c, err = cid.Decode(key)
+	is.Nil(err)
+	is.Equal(c.Type(), uint64(cid.DagCBOR))
+	is.Equal(c.Prefix().MhType, uint64(mh.SHA2_512))
+	var n int
+	is.Nil(s.DagGetWithOptsCtx(ctx, key+"/a", &n))
+	is.Equal(n, 1)
+	pins, err := s.PinsOfType(ctx, RecursivePin)
+	is.Nil(err)
+	_, ok := pins[key]
+	is.True(ok)
+
+	big := bytes.Repeat([]byte{1}, 2<<20)
+	_, err = s.BlockPutWithOptsCtx(ctx, big)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "over 1MiB"))
+	_, err = s.BlockPutWithOptsCtx(ctx, big, options.Block.AllowBigBlock(true))
+	is.Nil(err)
+
+	_, err = s.BlockPutWithOptsCtx(ctx, []byte("x"), options.Block.Hash("nope", -1))
+	is.Err(err)
+}
+
+func TestBlockPutMany(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	blocks := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	keys, err := s.BlockPutMany(ctx, blocks)
+	is.Nil(err)
+	is.Equal(len(keys), 3)
+	for i, key := range keys {
+		data, err := s.BlockGetCtx(ctx, key)
+		is.Nil(err)
+		is.Equal(data, blocks[i])
+		_, size, err := s.BlockStatCtx(ctx, key)
+		is.Nil(err)
+		is.Equal(size, len(blocks[i]))
+	}
+}
+
+func TestBlockRm(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	keys, err := s.BlockPutMany(ctx, [][]byte{[]byte("one"), []byte("two")})
+	is.Nil(err)
+	pinned, err := s.BlockPutWithOptsCtx(ctx, []byte("pinned"), options.Block.Pin(true))
+	is.Nil(err)
+
+	results, err := s.BlockRm(ctx, append(keys, pinned), false)
+	is.Nil(err)
+	var removed []string
+	var failed []BlockRmResult
+	for res := range results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		} else {
+			removed = append(removed, res.Hash)
+		}
+	}
+	is.Equal(removed, keys)
+	is.Equal(len(failed), 1)
+	is.Equal(failed[0].Hash, pinned)
+	is.True(strings.Contains(failed[0].Err.Error(), "pinned"))
+
+	_, err = s.BlockGetCtx(ctx, keys[0])
+	is.Err(err)
+
+	results, err = s.BlockRm(ctx, keys[:1], false)
+	is.Nil(err)
+	res := <-results
+	is.Equal(res.Hash, keys[0])
+	is.True(errors.Is(res.Err, ErrNotFound))
+
+	// missing blocks are ignored with force
+	results, err = s.BlockRm(ctx, keys[:1], true)
+	is.Nil(err)
+	for res := range results {
+		is.Nil(res.Err)
+		is.Equal(res.Hash, keys[0])
+	}
+
+	_, err = s.BlockRm(ctx, []string{"not a cid"}, false)
+	is.Err(err)
+}
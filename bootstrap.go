@@ -9,19 +9,34 @@ type PeersList struct {
 }
 
 func (s *Shell) BootstrapAdd(peers []string) ([]string, error) {
+	return s.BootstrapAddCtx(context.Background(), peers)
+}
+
+// BootstrapAddCtx is like BootstrapAdd but with a context.
+func (s *Shell) BootstrapAddCtx(ctx context.Context, peers []string) ([]string, error) {
 	var addOutput PeersList
-	err := s.Request("bootstrap/add", peers...).Exec(context.Background(), &addOutput)
+	err := s.Request("bootstrap/add", peers...).Exec(ctx, &addOutput)
 	return addOutput.Peers, err
 }
 
 func (s *Shell) BootstrapAddDefault() ([]string, error) {
+	return s.BootstrapAddDefaultCtx(context.Background())
+}
+
+// BootstrapAddDefaultCtx is like BootstrapAddDefault but with a context.
+func (s *Shell) BootstrapAddDefaultCtx(ctx context.Context) ([]string, error) {
 	var addOutput PeersList
-	err := s.Request("bootstrap/add/default").Exec(context.Background(), &addOutput)
+	err := s.Request("bootstrap/add/default").Exec(ctx, &addOutput)
 	return addOutput.Peers, err
 }
 
 func (s *Shell) BootstrapRmAll() ([]string, error) {
+	return s.BootstrapRmAllCtx(context.Background())
+}
+
+// BootstrapRmAllCtx is like BootstrapRmAll but with a context.
+func (s *Shell) BootstrapRmAllCtx(ctx context.Context) ([]string, error) {
 	var rmAllOutput PeersList
-	err := s.Request("bootstrap/rm/all").Exec(context.Background(), &rmAllOutput)
+	err := s.Request("bootstrap/rm/all").Exec(ctx, &rmAllOutput)
 	return rmAllOutput.Peers, err
 }
@@ -0,0 +1,276 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	car "github.com/ipld/go-car/v2"
+	"github.com/multiformats/go-varint"
+)
+
+// Errors wrapped by CARError, telling what is wrong with a CAR.
+var (
+	// ErrCARHeader is returned for an unreadable CAR header.
+	ErrCARHeader = errors.New("invalid CAR header")
+	// ErrCARTruncated is returned for a CAR that ends in the middle of a
+	// section.
+	ErrCARTruncated = errors.New("truncated CAR")
+	// ErrCARBlockMismatch is returned for a block that doesn't match its
+	// CID.
+	ErrCARBlockMismatch = errors.New("block does not match its CID")
+	// ErrCARRootMissing is returned for a root listed in the CAR header that
+	// isn't among its blocks.
+	ErrCARRootMissing = errors.New("root not found in CAR")
+)
+
+// CARError is an invalid CAR found by the Validate import option.
+type CARError struct {
+	// Name is the path of the CAR, if it was imported from a file.
+	Name string
+	// Offset is the offset in the CAR of the section at fault, or of the end
+	// of the blocks for a missing root.
+	Offset uint64
+	// Cid is the CID of the block at fault, or of the missing root, if
+	// known.
+	Cid cid.Cid
+	Err error
+}
+
+func (e *CARError) Error() string {
+	msg := fmt.Sprintf("CAR offset %d", e.Offset)
+	if e.Name != "" {
+		msg = fmt.Sprintf("%s: %s", e.Name, msg)
+	}
+	if e.Cid.Defined() {
+		msg = fmt.Sprintf("%s: %s", msg, e.Cid)
+	}
+	return fmt.Sprintf("%s: %s", msg, e.Err)
+}
+
+func (e *CARError) Unwrap() error {
+	return e.Err
+}
+
+type carState int
+
+const (
+	carHeader carState = iota
+	carBlocks
+	carIndex
+	carDone
+)
+
+// carValidator checks a CARv1 or CARv2 as it is read through it. Sections are
+// only handed out once they are checked, so an invalid block is never read
+// out of it; the CARv2 index, if any, is passed through as is.
+type carValidator struct {
+	r     *bufio.Reader
+	name  string
+	state carState
+	// pos is the offset in the CAR of the next byte of r.
+	pos uint64
+	// payload is the number of bytes left in the data payload of a CARv2,
+	// negative for a CARv1.
+	payload int64
+	roots   []cid.Cid
+	blocks  *cid.Set
+	out     []byte
+	err     error
+}
+
+func newCARValidator(r io.Reader, name string) *carValidator {
+	return &carValidator{
+		r:       bufio.NewReader(r),
+		name:    name,
+		payload: -1,
+		blocks:  cid.NewSet(),
+	}
+}
+
+func (v *carValidator) Read(p []byte) (int, error) {
+	for len(v.out) == 0 {
+		if v.err != nil {
+			return 0, v.err
+		}
+		v.out, v.err = v.next()
+	}
+	n := copy(p, v.out)
+	v.out = v.out[n:]
+	return n, nil
+}
+
+// readHeader checks the header of the CAR, setting its roots.
+func (v *carValidator) readHeader() error {
+	if v.state != carHeader {
+		return nil
+	}
+	v.out, v.err = v.next()
+	return v.err
+}
+
+func (v *carValidator) fail(offset uint64, c cid.Cid, err error) error {
+	return &CARError{Name: v.name, Offset: offset, Cid: c, Err: err}
+}
+
+// next checks and returns the next part of the CAR.
+func (v *carValidator) next() ([]byte, error) {
+	switch v.state {
+	case carHeader:
+		return v.header()
+	case carBlocks:
+		if v.payload == 0 {
+			if err := v.checkRoots(); err != nil {
+				return nil, err
+			}
+			v.state = carIndex
+			return nil, nil
+		}
+		if v.payload < 0 {
+			if _, err := v.r.Peek(1); err == io.EOF {
+				v.state = carDone
+				if err := v.checkRoots(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+		}
+		return v.block()
+	case carIndex:
+		buf := make([]byte, 32*1024)
+		n, err := v.r.Read(buf)
+		v.pos += uint64(n)
+		return buf[:n], err
+	}
+	return nil, io.EOF
+}
+
+// section reads a varint prefixed section of at most max bytes, returning it
+// whole along with its content.
+func (v *carValidator) section(max uint64) (raw, data []byte, err error) {
+	l, err := varint.ReadUvarint(v.r)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, nil, ErrCARTruncated
+	} else if err != nil {
+		return nil, nil, err
+	}
+	prefix := varint.ToUvarint(l)
+	if l == 0 {
+		return nil, nil, errors.New("empty section")
+	}
+	if l > max {
+		return nil, nil, fmt.Errorf("section of %d bytes exceeds the maximum of %d", l, max)
+	}
+	if v.payload >= 0 && uint64(len(prefix))+l > uint64(v.payload) {
+		return nil, nil, errors.New("section overflows the CARv2 data payload")
+	}
+
+	raw = make([]byte, len(prefix)+int(l))
+	copy(raw, prefix)
+	if _, err := io.ReadFull(v.r, raw[len(prefix):]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrCARTruncated
+		}
+		return nil, nil, err
+	}
+	return raw, raw[len(prefix):], nil
+}
+
+func (v *carValidator) header() ([]byte, error) {
+	start := v.pos
+	raw, _, err := v.section(car.DefaultMaxAllowedHeaderSize)
+	if err != nil {
+		return nil, v.fail(start, cid.Undef, fmt.Errorf("%w: %w", ErrCARHeader, err))
+	}
+	v.pos += uint64(len(raw))
+	version, err := car.ReadVersion(bytes.NewReader(raw))
+	if err != nil {
+		return nil, v.fail(start, cid.Undef, fmt.Errorf("%w: %w", ErrCARHeader, err))
+	}
+
+	switch {
+	case version == 1:
+		br, err := car.NewBlockReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, v.fail(start, cid.Undef, fmt.Errorf("%w: %w", ErrCARHeader, err))
+		}
+		v.roots = br.Roots
+		if v.payload > 0 {
+			v.payload -= int64(len(raw))
+		}
+		v.state = carBlocks
+		return raw, nil
+	case version == 2 && v.payload < 0 && start == 0:
+		return v.headerV2(raw)
+	}
+	return nil, v.fail(start, cid.Undef, fmt.Errorf("%w: unsupported version %d", ErrCARHeader, version))
+}
+
+// headerV2 reads the CARv2 header following the pragma, and the header of the
+// data payload.
+func (v *carValidator) headerV2(pragma []byte) ([]byte, error) {
+	out := bytes.NewBuffer(pragma)
+	if _, err := io.CopyN(out, v.r, car.HeaderSize); err != nil {
+		return nil, v.fail(v.pos, cid.Undef, fmt.Errorf("%w: %w", ErrCARHeader, ErrCARTruncated))
+	}
+	var h car.Header
+	if _, err := h.ReadFrom(bytes.NewReader(out.Bytes()[len(pragma):])); err != nil {
+		return nil, v.fail(v.pos, cid.Undef, fmt.Errorf("%w: %w", ErrCARHeader, err))
+	}
+	v.pos += car.HeaderSize
+	if h.DataOffset < v.pos || h.DataSize == 0 {
+		return nil, v.fail(uint64(len(pragma)), cid.Undef, fmt.Errorf("%w: invalid data payload position", ErrCARHeader))
+	}
+
+	// padding
+	if _, err := io.CopyN(out, v.r, int64(h.DataOffset-v.pos)); err != nil {
+		return nil, v.fail(v.pos, cid.Undef, ErrCARTruncated)
+	}
+	v.pos = h.DataOffset
+	v.payload = int64(h.DataSize)
+
+	payloadHeader, err := v.header()
+	if err != nil {
+		return nil, err
+	}
+	out.Write(payloadHeader)
+	return out.Bytes(), nil
+}
+
+func (v *carValidator) block() ([]byte, error) {
+	start := v.pos
+	raw, data, err := v.section(car.DefaultMaxAllowedSectionSize)
+	if err != nil {
+		return nil, v.fail(start, cid.Undef, err)
+	}
+	n, c, err := cid.CidFromBytes(data)
+	if err != nil {
+		return nil, v.fail(start, cid.Undef, fmt.Errorf("invalid CID: %w", err))
+	}
+	hashed, err := c.Prefix().Sum(data[n:])
+	if err != nil {
+		return nil, v.fail(start, c, err)
+	}
+	if !hashed.Equals(c) {
+		return nil, v.fail(start, c, ErrCARBlockMismatch)
+	}
+
+	v.blocks.Add(c)
+	v.pos += uint64(len(raw))
+	if v.payload > 0 {
+		v.payload -= int64(len(raw))
+	}
+	return raw, nil
+}
+
+func (v *carValidator) checkRoots() error {
+	for _, r := range v.roots {
+		if !v.blocks.Has(r) {
+			return v.fail(v.pos, r, ErrCARRootMissing)
+		}
+	}
+	return nil
+}
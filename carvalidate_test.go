@@ -0,0 +1,140 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	files "github.com/ipfs/boxo/files"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+	car "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/storage"
+)
+
+// lastBlockOffset returns the offset of the last block of a CAR.
+func lastBlockOffset(t *testing.T, data []byte) uint64 {
+	br, err := car.NewBlockReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last uint64
+	for {
+		md, err := br.SkipNext()
+		if err == io.EOF {
+			return last
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = md.Offset
+	}
+}
+
+func TestCARValidator(t *testing.T) {
+	is := is.New(t)
+	v1, err := os.ReadFile("./tests/test.car")
+	is.Nil(err)
+	var v2 bytes.Buffer
+	is.Nil(car.WrapV1(bytes.NewReader(v1), &v2))
+
+	for _, data := range [][]byte{v1, v2.Bytes()} {
+		v := newCARValidator(bytes.NewReader(data), "")
+		out, err := io.ReadAll(v)
+		is.Nil(err)
+		is.Equal(out, data)
+		is.Equal(len(v.roots), 1)
+		is.Equal(v.roots[0].String(), "bafybeibnhml2ecayjfa747ryfuy3ws5im6q4kscapqv7ajaspezwsw63ee")
+	}
+
+	corrupt := append([]byte(nil), v1...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	out, err := io.ReadAll(newCARValidator(bytes.NewReader(corrupt), "test.car"))
+	var cerr *CARError
+	is.True(errors.As(err, &cerr))
+	is.True(errors.Is(err, ErrCARBlockMismatch))
+	is.Equal(cerr.Name, "test.car")
+	is.Equal(cerr.Offset, lastBlockOffset(t, v1))
+	is.True(cerr.Cid.Defined())
+	// nothing of the corrupt block is read out
+	is.Equal(out, v1[:cerr.Offset])
+
+	_, err = io.ReadAll(newCARValidator(bytes.NewReader(v1[:len(v1)-3]), ""))
+	is.True(errors.Is(err, ErrCARTruncated))
+	is.True(errors.As(err, &cerr))
+	is.Equal(cerr.Offset, lastBlockOffset(t, v1))
+
+	_, err = io.ReadAll(newCARValidator(bytes.NewReader(v2.Bytes()[:100]), ""))
+	is.True(errors.Is(err, ErrCARTruncated))
+
+	_, err = io.ReadAll(newCARValidator(bytes.NewReader([]byte("not a CAR")), ""))
+	is.True(errors.Is(err, ErrCARHeader))
+	is.True(errors.As(err, &cerr))
+	is.Equal(cerr.Offset, uint64(0))
+
+	// a root that isn't in the CAR
+	missing := blocks.NewBlock([]byte("missing"))
+	present := blocks.NewBlock([]byte("present"))
+	var noRoot bytes.Buffer
+	w, err := storage.NewWritable(&noRoot, []cid.Cid{missing.Cid()}, car.WriteAsCarV1(true))
+	is.Nil(err)
+	is.Nil(w.Put(context.Background(), present.Cid().KeyString(), present.RawData()))
+	_, err = io.ReadAll(newCARValidator(&noRoot, ""))
+	is.True(errors.Is(err, ErrCARRootMissing))
+	is.True(errors.As(err, &cerr))
+	is.Equal(cerr.Cid, missing.Cid())
+}
+
+func TestDagImportValidate(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	data, err := os.ReadFile("./tests/test.car")
+	is.Nil(err)
+	out, err := s.DagImportWithOptsCtx(ctx, data, options.Dag.Validate(true), options.Dag.Stats(true))
+	is.Nil(err)
+	is.Equal(out.Stats.BlockCount, 5)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	_, err = s.DagImportWithOptsCtx(ctx, bytes.NewReader(corrupt), options.Dag.Validate(true))
+	var cerr *CARError
+	is.True(errors.As(err, &cerr))
+	is.True(errors.Is(err, ErrCARBlockMismatch))
+
+	// a multipart body holding several CARs can't be validated
+	mfr, err := s.newMultiFileReader(ctx, files.NewSliceDirectory([]files.DirEntry{
+		files.FileEntry("", files.NewBytesFile(data)),
+	}))
+	is.Nil(err)
+	_, err = s.DagImportWithOptsCtx(ctx, mfr, options.Dag.Validate(true))
+	is.Err(err)
+	is.False(errors.As(err, &cerr))
+	is.True(strings.Contains(err.Error(), "DagImportReaders"))
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.car")
+	bad := filepath.Join(dir, "bad.car")
+	is.Nil(os.WriteFile(good, data, 0o644))
+	is.Nil(os.WriteFile(bad, corrupt, 0o644))
+	_, err = s.DagImportFiles(ctx, []string{good, bad}, options.Dag.Validate(true))
+	is.True(errors.As(err, &cerr))
+	is.Equal(cerr.Name, bad)
+	is.Equal(cerr.Offset, lastBlockOffset(t, data))
+
+	cars, err := s.DagImportFS(ctx, os.DirFS("./tests/cars"), options.Dag.Validate(true))
+	is.Nil(err)
+	is.Equal(len(cars.CARs), 2)
+	is.Equal(len(cars.CARs[0].Roots), 1)
+}
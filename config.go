@@ -0,0 +1,471 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	files "github.com/ipfs/boxo/files"
+)
+
+// Config is the configuration of the daemon, as returned by ConfigShow.
+//
+// Sections that are rarely edited, or whose shape changes across daemon
+// versions, are kept as raw JSON. Optional values the daemon falls back to a
+// default for are pointers, nil meaning default.
+//
+// A Config decoded from JSON remembers the entries it has no field for, and
+// encodes them back along with its fields, so that a Config read with
+// ConfigShow can be edited and given back to ConfigReplace without dropping
+// the settings of newer daemons.
+type Config struct {
+	Identity     ConfigIdentity
+	Datastore    ConfigDatastore
+	Addresses    ConfigAddresses
+	Mounts       ConfigMounts
+	Discovery    ConfigDiscovery
+	Routing      ConfigRouting
+	Ipns         ConfigIpns
+	Bootstrap    []string
+	Gateway      ConfigGateway
+	API          ConfigAPI
+	Swarm        ConfigSwarm
+	AutoNAT      json.RawMessage `json:",omitempty"`
+	Pubsub       ConfigPubsub
+	Peering      ConfigPeering
+	DNS          ConfigDNS
+	Migration    ConfigMigration
+	Provider     ConfigProvider
+	Reprovider   ConfigReprovider
+	Experimental ConfigExperiments
+	Plugins      ConfigPlugins
+	Pinning      ConfigPinning
+	Internal     json.RawMessage `json:",omitempty"`
+
+	// raw is the document the config was decoded from.
+	raw map[string]interface{}
+}
+
+// configFields is Config without its methods.
+type configFields Config
+
+// UnmarshalJSON decodes the config, remembering the whole document.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var fields configFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var raw map[string]interface{}
+	if err := decodeJSONNumber(data, &raw); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	c.raw = raw
+	return nil
+}
+
+// MarshalJSON encodes the config, along with the entries of the document it
+// was decoded from that it has no field for.
+func (c Config) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(configFields(c))
+	if err != nil || c.raw == nil {
+		return data, err
+	}
+	var fields map[string]interface{}
+	if err := decodeJSONNumber(data, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeConfig(c.raw, fields, reflect.TypeOf(c)))
+}
+
+// decodeJSONNumber decodes data into v, keeping numbers as json.Number so
+// that they are encoded back as they were.
+func decodeJSONNumber(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mergeConfig returns the entries of raw unknown to the struct type t, along
+// with fields, the encoding of a t. The objects of struct fields are merged
+// the same way, other values are taken from fields as a whole; a field left
+// out of fields, like a nil pointer with omitempty, is removed.
+func mergeConfig(raw, fields map[string]interface{}, t reflect.Type) map[string]interface{} {
+	out := make(map[string]interface{}, len(raw)+len(fields))
+	for k, v := range raw {
+		out[k] = v
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" {
+			name = tag
+		}
+		v, ok := fields[name]
+		if !ok {
+			delete(out, name)
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		rm, rawIsMap := out[name].(map[string]interface{})
+		fm, isMap := v.(map[string]interface{})
+		if ft.Kind() == reflect.Struct && rawIsMap && isMap {
+			v = mergeConfig(rm, fm, ft)
+		}
+		out[name] = v
+	}
+	return out
+}
+
+// ConfigIdentity is the identity of the node. The private key is never sent
+// by the daemon.
+type ConfigIdentity struct {
+	PeerID  string
+	PrivKey string `json:",omitempty"`
+}
+
+type ConfigDatastore struct {
+	// StorageMax is a size like "10GB".
+	StorageMax string
+	// StorageGCWatermark is the percentage of StorageMax above which the
+	// periodic GC runs.
+	StorageGCWatermark int64
+	// GCPeriod is a duration like "1h".
+	GCPeriod string
+
+	Spec            map[string]interface{}
+	HashOnRead      bool
+	BloomFilterSize int
+}
+
+type ConfigAddresses struct {
+	Swarm          []string
+	Announce       []string
+	AppendAnnounce []string
+	NoAnnounce     []string
+	API            ConfigStrings
+	Gateway        ConfigStrings
+}
+
+// ConfigStrings is a list of strings that the daemon also accepts as a single
+// string.
+type ConfigStrings []string
+
+// UnmarshalJSON accepts a string, a list of strings or null.
+func (s *ConfigStrings) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = ConfigStrings{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
+type ConfigMounts struct {
+	IPFS           string
+	IPNS           string
+	FuseAllowOther bool
+}
+
+type ConfigDiscovery struct {
+	MDNS struct {
+		Enabled bool
+	}
+}
+
+type ConfigRouting struct {
+	Type                 *string `json:",omitempty"`
+	AcceleratedDHTClient bool
+	Routers              json.RawMessage `json:",omitempty"`
+	Methods              json.RawMessage `json:",omitempty"`
+}
+
+type ConfigIpns struct {
+	RepublishPeriod  string
+	RecordLifetime   string
+	ResolveCacheSize int
+	UsePubsub        *bool `json:",omitempty"`
+}
+
+type ConfigGateway struct {
+	HTTPHeaders           map[string][]string
+	RootRedirect          string
+	Writable              *bool `json:",omitempty"`
+	PathPrefixes          []string
+	APICommands           []string
+	NoFetch               bool
+	NoDNSLink             bool
+	DeserializedResponses *bool `json:",omitempty"`
+	PublicGateways        map[string]*ConfigGatewaySpec
+}
+
+type ConfigGatewaySpec struct {
+	Paths                 []string
+	UseSubdomains         bool
+	NoDNSLink             bool
+	InlineDNSLink         *bool `json:",omitempty"`
+	DeserializedResponses *bool `json:",omitempty"`
+}
+
+type ConfigAPI struct {
+	HTTPHeaders    map[string][]string
+	Authorizations map[string]*ConfigAPIAuthorization `json:",omitempty"`
+}
+
+// ConfigAPIAuthorization grants access to the RPC API paths in AllowedPaths
+// to the clients presenting AuthSecret.
+type ConfigAPIAuthorization struct {
+	AuthSecret   string
+	AllowedPaths []string
+}
+
+type ConfigSwarm struct {
+	AddrFilters             []string
+	DisableBandwidthMetrics bool
+	DisableNatPortMap       bool
+	RelayClient             struct {
+		Enabled      *bool    `json:",omitempty"`
+		StaticRelays []string `json:",omitempty"`
+	}
+	RelayService       json.RawMessage `json:",omitempty"`
+	EnableHolePunching *bool           `json:",omitempty"`
+	Transports         json.RawMessage `json:",omitempty"`
+	ConnMgr            struct {
+		Type        *string `json:",omitempty"`
+		LowWater    *int64  `json:",omitempty"`
+		HighWater   *int64  `json:",omitempty"`
+		GracePeriod *string `json:",omitempty"`
+	}
+	ResourceMgr json.RawMessage `json:",omitempty"`
+}
+
+type ConfigPubsub struct {
+	Router               string
+	DisableSigning       bool
+	Enabled              *bool   `json:",omitempty"`
+	SeenMessagesTTL      *string `json:",omitempty"`
+	SeenMessagesStrategy *string `json:",omitempty"`
+}
+
+type ConfigPeering struct {
+	Peers []ConfigPeer
+}
+
+// ConfigPeer is a peer the node stays connected to.
+type ConfigPeer struct {
+	ID    string
+	Addrs []string
+}
+
+type ConfigDNS struct {
+	Resolvers   map[string]string
+	MaxCacheTTL *string `json:",omitempty"`
+}
+
+type ConfigMigration struct {
+	DownloadSources []string
+	Keep            string
+}
+
+type ConfigProvider struct {
+	Strategy string
+}
+
+type ConfigReprovider struct {
+	Interval *string `json:",omitempty"`
+	Strategy *string `json:",omitempty"`
+}
+
+type ConfigExperiments struct {
+	FilestoreEnabled              bool
+	UrlstoreEnabled               bool
+	GraphsyncEnabled              bool
+	Libp2pStreamMounting          bool
+	P2pHttpProxy                  bool
+	StrategicProviding            bool
+	OptimisticProvide             bool
+	OptimisticProvideJobsPoolSize int
+}
+
+type ConfigPlugins struct {
+	Plugins map[string]ConfigPlugin
+}
+
+type ConfigPlugin struct {
+	Disabled bool
+	Config   interface{}
+}
+
+type ConfigPinning struct {
+	RemoteServices map[string]ConfigRemotePinningService
+}
+
+// ConfigRemotePinningService is a remote pinning service, see
+// PinRemoteServiceAdd. The daemon never sends the API key.
+type ConfigRemotePinningService struct {
+	API struct {
+		Endpoint string
+		Key      string `json:",omitempty"`
+	}
+	Policies struct {
+		MFS struct {
+			Enable        bool
+			PinName       string
+			RepinInterval string
+		}
+	}
+}
+
+// ConfigGet returns the value of the config entry key, like
+// "Addresses.API", decoded from JSON: objects are returned as
+// map[string]interface{}, and numbers as float64.
+func (s *Shell) ConfigGet(ctx context.Context, key string) (interface{}, error) {
+	var out struct {
+		Key   string
+		Value interface{}
+	}
+	if err := s.Request("config", key).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out.Value, nil
+}
+
+// ConfigSet sets the config entry key to value. If json is true, value is
+// sent as JSON: a string or a json.RawMessage is taken to be JSON already,
+// and other values are encoded. Otherwise, value must be a string or a bool.
+func (s *Shell) ConfigSet(ctx context.Context, key string, value interface{}, json bool) error {
+	rb := s.Request("config")
+	switch v := value.(type) {
+	case string:
+		rb.Arguments(key, v).Option("json", json)
+	case bool:
+		if json {
+			rb.Arguments(key, fmt.Sprint(v)).Option("json", true)
+		} else {
+			rb.Arguments(key, fmt.Sprint(v)).Option("bool", true)
+		}
+	default:
+		if !json {
+			return fmt.Errorf("config: cannot set %s to a %T without json", key, value)
+		}
+		data, err := marshalJSON(value)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		rb.Arguments(key, string(data)).Option("json", true)
+	}
+	return rb.Exec(ctx, nil)
+}
+
+// marshalJSON is json.Marshal, but leaves json.RawMessage values alone, even
+// if they are nil.
+func marshalJSON(v interface{}) ([]byte, error) {
+	if raw, ok := v.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
+
+// ConfigShow returns the whole config of the daemon.
+func (s *Shell) ConfigShow(ctx context.Context) (*Config, error) {
+	var out Config
+	if err := s.Request("config/show").Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfigReplace replaces the whole config of the daemon with the JSON config
+// read from r, which may be an encoded Config. Entries missing from r are
+// removed from the config, so a Config should come from ConfigShow rather
+// than be built from scratch. The private key and the remote pinning
+// services can't be changed this way. Most changes only take effect once the
+// daemon restarts.
+func (s *Shell) ConfigReplace(ctx context.Context, r io.Reader) error {
+	fr := files.NewReaderFile(r)
+	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
+	fileReader, err := s.newMultiFileReader(ctx, slf)
+	if err != nil {
+		return err
+	}
+	return s.Request("config/replace").Body(fileReader).Exec(ctx, nil)
+}
+
+// ConfigChange is a config entry changed by a profile. Old is nil for added
+// entries, and New for removed ones.
+type ConfigChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// ConfigProfileResult is the outcome of applying a config profile.
+type ConfigProfileResult struct {
+	OldCfg map[string]interface{}
+	NewCfg map[string]interface{}
+	// Changes lists the entries that differ between OldCfg and NewCfg,
+	// sorted by key.
+	Changes []ConfigChange `json:"-"`
+}
+
+// ConfigProfileApply applies the config profile name, like "server" or
+// "lowpower", and returns the config before and after. If dryRun is true,
+// the config is left unchanged.
+func (s *Shell) ConfigProfileApply(ctx context.Context, name string, dryRun bool) (*ConfigProfileResult, error) {
+	var out ConfigProfileResult
+	if err := s.Request("config/profile/apply", name).
+		Option("dry-run", dryRun).
+		Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	out.Changes = diffConfig("", out.OldCfg, out.NewCfg, nil)
+	sort.Slice(out.Changes, func(i, j int) bool {
+		return out.Changes[i].Key < out.Changes[j].Key
+	})
+	return &out, nil
+}
+
+// diffConfig appends the changes between two config objects to changes.
+// Objects are compared key by key, other values as a whole.
+func diffConfig(prefix string, old, new map[string]interface{}, changes []ConfigChange) []ConfigChange {
+	key := func(k string) string {
+		return strings.TrimPrefix(prefix+"."+k, ".")
+	}
+	for k, ov := range old {
+		nv, ok := new[k]
+		if !ok {
+			changes = append(changes, ConfigChange{Key: key(k), Old: ov})
+			continue
+		}
+		om, oIsMap := ov.(map[string]interface{})
+		nm, nIsMap := nv.(map[string]interface{})
+		switch {
+		case oIsMap && nIsMap:
+			changes = diffConfig(key(k), om, nm, changes)
+		case !reflect.DeepEqual(ov, nv):
+			changes = append(changes, ConfigChange{Key: key(k), Old: ov, New: nv})
+		}
+	}
+	for k, nv := range new {
+		if _, ok := old[k]; !ok {
+			changes = append(changes, ConfigChange{Key: key(k), New: nv})
+		}
+	}
+	return changes
+}
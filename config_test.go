@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+)
+
+func TestConfigGetSet(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	api, err := s.ConfigGet(ctx, "Addresses.API")
+	is.Nil(err)
+	is.Equal(api, "/ip4/127.0.0.1/tcp/5001")
+
+	is.Nil(s.ConfigSet(ctx, "Addresses.API", "/ip4/127.0.0.1/tcp/5002", false))
+	is.Nil(s.ConfigSet(ctx, "Discovery.MDNS.Enabled", false, false))
+	is.Nil(s.ConfigSet(ctx, "Swarm.ConnMgr.HighWater", 100, true))
+	is.Nil(s.ConfigSet(ctx, "Bootstrap", json.RawMessage(`[]`), true))
+	is.Nil(s.ConfigSet(ctx, "Peering.Peers", []ConfigPeer{{ID: "12D3KooWExample", Addrs: []string{"/ip4/1.2.3.4/tcp/4001"}}}, true))
+
+	err = s.ConfigSet(ctx, "Swarm.ConnMgr.LowWater", 50, false)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "without json"))
+
+	err = s.ConfigSet(ctx, "Bootstrap", "[not json", true)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "failed to unmarshal json"))
+
+	_, err = s.ConfigGet(ctx, "Identity.PrivKey")
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "private key"))
+
+	_, err = s.ConfigGet(ctx, "Nope.Nope")
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "Nope not found"))
+
+	highWater, err := s.ConfigGet(ctx, "Swarm.ConnMgr.HighWater")
+	is.Nil(err)
+	is.Equal(highWater, float64(100))
+
+	cfg, err := s.ConfigShow(ctx)
+	is.Nil(err)
+	is.NotEqual(cfg.Identity.PeerID, "")
+	is.Equal(cfg.Identity.PrivKey, "")
+	is.Equal(cfg.Addresses.API, ConfigStrings{"/ip4/127.0.0.1/tcp/5002"})
+	is.False(cfg.Discovery.MDNS.Enabled)
+	is.Equal(*cfg.Swarm.ConnMgr.HighWater, int64(100))
+	is.Equal(len(cfg.Bootstrap), 0)
+	is.Equal(len(cfg.Peering.Peers), 1)
+	is.Equal(cfg.Peering.Peers[0].ID, "12D3KooWExample")
+}
+
+func TestConfigReplace(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	// entries Config has no field for, as set by newer daemons
+	is.Nil(s.ConfigSet(ctx, "Import", json.RawMessage(`{"CidVersion": 1}`), true))
+	is.Nil(s.ConfigSet(ctx, "Gateway.ExposeRoutingAPI", true, true))
+	is.Nil(s.ConfigSet(ctx, "Swarm.ConnMgr.Type", "basic", false))
+
+	cfg, err := s.ConfigShow(ctx)
+	is.Nil(err)
+	cfg.Gateway.RootRedirect = "/ipfs/bafkqaaa"
+	cfg.Swarm.ConnMgr.Type = nil
+	cfg.Addresses.Gateway = ConfigStrings{"/ip4/127.0.0.1/tcp/8081", "/ip6/::1/tcp/8081"}
+
+	var buf bytes.Buffer
+	is.Nil(json.NewEncoder(&buf).Encode(cfg))
+	is.Nil(s.ConfigReplace(ctx, &buf))
+
+	replaced, err := s.ConfigShow(ctx)
+	is.Nil(err)
+	is.Equal(replaced.Gateway.RootRedirect, "/ipfs/bafkqaaa")
+	is.Equal(replaced.Addresses.Gateway, cfg.Addresses.Gateway)
+	is.Equal(replaced.Identity.PeerID, cfg.Identity.PeerID)
+	// raw sections survive the round trip
+	is.True(len(replaced.Swarm.Transports) > 0)
+	// so do unknown entries, while cleared ones are removed
+	cidVersion, err := s.ConfigGet(ctx, "Import.CidVersion")
+	is.Nil(err)
+	is.Equal(cidVersion, float64(1))
+	exposeRouting, err := s.ConfigGet(ctx, "Gateway.ExposeRoutingAPI")
+	is.Nil(err)
+	is.Equal(exposeRouting, true)
+	is.Nil(replaced.Swarm.ConnMgr.Type)
+	_, err = s.ConfigGet(ctx, "Swarm.ConnMgr.Type")
+	is.Err(err)
+
+	cfg.Identity.PrivKey = "CAESQ..."
+	buf.Reset()
+	is.Nil(json.NewEncoder(&buf).Encode(cfg))
+	err = s.ConfigReplace(ctx, &buf)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "private key"))
+
+	err = s.ConfigReplace(ctx, strings.NewReader("not a config"))
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "failed to decode file as config"))
+}
+
+func TestConfigProfileApply(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	res, err := s.ConfigProfileApply(ctx, "test", true)
+	is.Nil(err)
+	changed := make(map[string]ConfigChange)
+	for _, c := range res.Changes {
+		changed[c.Key] = c
+	}
+	is.Equal(changed["Addresses.API"].Old, "/ip4/127.0.0.1/tcp/5001")
+	is.Equal(changed["Addresses.API"].New, "/ip4/127.0.0.1/tcp/0")
+	is.Equal(changed["Discovery.MDNS.Enabled"].New, false)
+	_, ok := changed["Bootstrap"]
+	is.True(ok)
+	for i := 1; i < len(res.Changes); i++ {
+		is.True(res.Changes[i-1].Key < res.Changes[i].Key)
+	}
+
+	// a dry run leaves the config alone
+	api, err := s.ConfigGet(ctx, "Addresses.API")
+	is.Nil(err)
+	is.Equal(api, "/ip4/127.0.0.1/tcp/5001")
+
+	res, err = s.ConfigProfileApply(ctx, "lowpower", false)
+	is.Nil(err)
+	var keys []string
+	for _, c := range res.Changes {
+		keys = append(keys, c.Key)
+	}
+	is.Equal(strings.Join(keys, " "), "AutoNAT.ServiceMode Reprovider.Interval Routing.Type "+
+		"Swarm.ConnMgr.GracePeriod Swarm.ConnMgr.HighWater Swarm.ConnMgr.LowWater Swarm.ConnMgr.Type")
+
+	cfg, err := s.ConfigShow(ctx)
+	is.Nil(err)
+	is.Equal(*cfg.Routing.Type, "dhtclient")
+	is.Equal(*cfg.Swarm.ConnMgr.LowWater, int64(20))
+
+	_, err = s.ConfigProfileApply(ctx, "nope", false)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "nope is not a profile"))
+}
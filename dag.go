@@ -4,12 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 
 	files "github.com/ipfs/boxo/files"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-api/options"
+	car "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-ipld-prime/codec"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/codec/raw"
 )
 
 type DagPutOutput struct {
@@ -23,6 +33,8 @@ type DagImportRoot struct {
 		Cid struct {
 			Value string `json:"/"`
 		}
+		// PinErrorMsg is set if the root couldn't be pinned.
+		PinErrorMsg string `json:",omitempty"`
 	}
 	Stats *DagImportStats `json:"Stats,omitempty"`
 }
@@ -37,21 +49,102 @@ type DagImportOutput struct {
 	Stats *DagImportStats
 }
 
+// DagGet gets the node at ref and decodes its dag-json form into out with
+// encoding/json, so links and bytes are left as {"/": ...} objects. See
+// DagGetWithOpts to decode them into cid.Cid and []byte values.
 func (s *Shell) DagGet(ref string, out interface{}) error {
-	return s.Request("dag/get", ref).Exec(context.Background(), out)
+	return s.DagGetCtx(context.Background(), ref, out)
+}
+
+// DagGetCtx is like DagGet but with a context.
+func (s *Shell) DagGetCtx(ctx context.Context, ref string, out interface{}) error {
+	return s.Request("dag/get", ref).Exec(ctx, out)
+}
+
+// DagGetWithOpts gets the node at ref, a CID optionally followed by a path
+// into the DAG, and decodes it into out, which must be a non-nil pointer.
+//
+// Unlike DagGet, the node is decoded with its IPLD codec rather than as plain
+// JSON: Go values are mapped like with encoding/json, json struct tags
+// included, except that links decode into cid.Cid and bytes into []byte. An
+// interface{} gets links and bytes as cid.Cid and []byte values, and integers
+// as int64.
+func (s *Shell) DagGetWithOpts(ref string, out interface{}, opts ...options.DagGetOption) error {
+	return s.DagGetWithOptsCtx(context.Background(), ref, out, opts...)
+}
+
+// DagGetWithOptsCtx is like DagGetWithOpts but with a context.
+func (s *Shell) DagGetWithOptsCtx(ctx context.Context, ref string, out interface{}, opts ...options.DagGetOption) error {
+	cfg, err := options.DagGetOptions(opts...)
+	if err != nil {
+		return err
+	}
+	data, err := s.dagGetRaw(ctx, ref, cfg)
+	if err != nil {
+		return err
+	}
+
+	var dec codec.Decoder
+	switch cfg.OutputCodec {
+	case "dag-json":
+		dec = dagjson.Decode
+	case "dag-cbor":
+		dec = dagcbor.Decode
+	case "raw":
+		dec = raw.Decode
+	}
+	if err := decodeValue(data, dec, out); err != nil {
+		return fmt.Errorf("dag get: decoding %s: %w", cfg.OutputCodec, err)
+	}
+	return nil
+}
+
+// DagGetRaw returns the node at ref, a CID optionally followed by a path into
+// the DAG, encoded with the output codec.
+func (s *Shell) DagGetRaw(ctx context.Context, ref string, opts ...options.DagGetOption) ([]byte, error) {
+	cfg, err := options.DagGetOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.dagGetRaw(ctx, ref, cfg)
+}
+
+func (s *Shell) dagGetRaw(ctx context.Context, ref string, cfg *options.DagGetSettings) ([]byte, error) {
+	resp, err := s.Request("dag/get", ref).
+		Option("output-codec", cfg.OutputCodec).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return io.ReadAll(resp.Output)
 }
 
 func (s *Shell) DagPut(data interface{}, inputCodec, storeCodec string) (string, error) {
 	return s.DagPutWithOpts(data, options.Dag.InputCodec(inputCodec), options.Dag.StoreCodec(storeCodec))
 }
 
+// DagPutCtx is like DagPut but with a context.
+func (s *Shell) DagPutCtx(ctx context.Context, data interface{}, inputCodec, storeCodec string) (string, error) {
+	return s.DagPutWithOptsCtx(ctx, data, options.Dag.InputCodec(inputCodec), options.Dag.StoreCodec(storeCodec))
+}
+
 func (s *Shell) DagPutWithOpts(data interface{}, opts ...options.DagPutOption) (string, error) {
+	return s.DagPutWithOptsCtx(context.Background(), data, opts...)
+}
+
+// DagPutWithOptsCtx is like DagPutWithOpts but with a context.
+func (s *Shell) DagPutWithOptsCtx(ctx context.Context, data interface{}, opts ...options.DagPutOption) (string, error) {
 	cfg, err := options.DagPutOptions(opts...)
 	if err != nil {
 		return "", err
 	}
 
-	fileReader, err := s.dagToFilesReader(data)
+	fileReader, err := s.dagToFilesReader(ctx, data)
 	if err != nil {
 		return "", err
 	}
@@ -65,7 +158,40 @@ func (s *Shell) DagPutWithOpts(data interface{}, opts ...options.DagPutOption) (
 		Option("pin", cfg.Pin).
 		Option("hash", cfg.Hash).
 		Body(fileReader).
-		Exec(context.Background(), &out)
+		Exec(ctx, &out)
+}
+
+// DagPutValue encodes v and stores it as a DAG node, returning its CID. v is
+// encoded client-side with the input codec, "dag-json" or "dag-cbor", and
+// Go values are mapped like with encoding/json, json struct tags included,
+// except that cid.Cid values are encoded as links and []byte values as bytes.
+// See DagGetValue to decode the node back.
+func (s *Shell) DagPutValue(ctx context.Context, v interface{}, opts ...options.DagPutOption) (string, error) {
+	cfg, err := options.DagPutOptions(opts...)
+	if err != nil {
+		return "", err
+	}
+
+	var enc codec.Encoder
+	switch cfg.InputCodec {
+	case "dag-json":
+		enc = dagjson.Encode
+	case "dag-cbor":
+		enc = dagcbor.Encode
+	default:
+		return "", fmt.Errorf("dag put: cannot encode values as %s", cfg.InputCodec)
+	}
+	data, err := encodeValue(v, enc)
+	if err != nil {
+		return "", fmt.Errorf("dag put: encoding %s: %w", cfg.InputCodec, err)
+	}
+	return s.DagPutWithOptsCtx(ctx, data, opts...)
+}
+
+// DagGetValue decodes the node at ref into v, which must be a non-nil
+// pointer. It is the counterpart of DagPutValue, and is DagGetWithOptsCtx.
+func (s *Shell) DagGetValue(ctx context.Context, ref string, v interface{}, opts ...options.DagGetOption) error {
+	return s.DagGetWithOptsCtx(ctx, ref, v, opts...)
 }
 
 // DagImport imports the contents of .car files (with default parameters)
@@ -73,25 +199,62 @@ func (s *Shell) DagImport(data interface{}, silent, stats bool) (*DagImportOutpu
 	return s.DagImportWithOpts(data, options.Dag.Silent(silent), options.Dag.Stats(stats))
 }
 
+// DagImportCtx is like DagImport but with a context.
+func (s *Shell) DagImportCtx(ctx context.Context, data interface{}, silent, stats bool) (*DagImportOutput, error) {
+	return s.DagImportWithOptsCtx(ctx, data, options.Dag.Silent(silent), options.Dag.Stats(stats))
+}
+
 // DagImportWithOpts imports the contents of .car files
 func (s *Shell) DagImportWithOpts(data interface{}, opts ...options.DagImportOption) (*DagImportOutput, error) {
+	return s.DagImportWithOptsCtx(context.Background(), data, opts...)
+}
+
+// DagImportWithOptsCtx is like DagImportWithOpts but with a context.
+func (s *Shell) DagImportWithOptsCtx(ctx context.Context, data interface{}, opts ...options.DagImportOption) (*DagImportOutput, error) {
 	cfg, err := options.DagImportOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	fileReader, err := s.dagToFilesReader(data)
+	var validator *carValidator
+	if cfg.Validate {
+		switch d := data.(type) {
+		case string:
+			validator = newCARValidator(strings.NewReader(d), "")
+		case []byte:
+			validator = newCARValidator(bytes.NewReader(d), "")
+		case *files.MultiFileReader:
+			// already multipart, the CARs in it can't be told apart
+			return nil, fmt.Errorf("dag import: cannot validate a %T, see DagImportReaders", data)
+		case io.Reader:
+			validator = newCARValidator(d, "")
+		default:
+			return nil, fmt.Errorf("dag import: cannot validate a %T, see DagImportReaders", data)
+		}
+		data = validator
+	}
+
+	fileReader, err := s.dagToFilesReader(ctx, data)
 	if err != nil {
 		return nil, err
 	}
 
+	out, err := s.dagImport(ctx, fileReader, cfg)
+	if err != nil && validator != nil && validator.err != nil && validator.err != io.EOF {
+		// the request fails with a wrapped read error
+		return nil, validator.err
+	}
+	return out, err
+}
+
+func (s *Shell) dagImport(ctx context.Context, fileReader *files.MultiFileReader, cfg *options.DagImportSettings) (*DagImportOutput, error) {
 	res, err := s.Request("dag/import").
 		Option("pin-roots", cfg.PinRoots).
 		Option("silent", cfg.Silent).
 		Option("stats", cfg.Stats).
 		Option("allow-big-block", cfg.AllowBigBlock).
 		Body(fileReader).
-		Send(context.Background())
+		Send(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +280,9 @@ func (s *Shell) DagImportWithOpts(data interface{}, opts ...options.DagImportOpt
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return nil, err
+		}
 
 		if root.Stats != nil {
 			out.Stats = root.Stats
@@ -127,10 +293,376 @@ func (s *Shell) DagImportWithOpts(data interface{}, opts ...options.DagImportOpt
 		out.Roots = append(out.Roots, root)
 	}
 
-	return &out, err
+	return &out, nil
+}
+
+// DagImportCAR is a CAR imported by DagImportFiles, DagImportFS or
+// DagImportReaders.
+type DagImportCAR struct {
+	// Name is the path of the CAR, empty for a reader.
+	Name string
+	// Roots are the roots listed in the header of the CAR.
+	Roots []string
+}
+
+// DagImportCARsOutput is the outcome of importing several CARs. The daemon
+// reports the roots of all the CARs together, in Roots; CARs tells which
+// CAR each root came from. Stats, the totals of all the CARs, are reported
+// unless disabled with options.Dag.Stats(false), or with
+// options.Dag.Silent(true) which leaves Roots empty too.
+type DagImportCARsOutput struct {
+	DagImportOutput
+	CARs []DagImportCAR
+}
+
+// DagImportFiles imports the CAR files at paths in a single request. To
+// import all the CARs in a directory, see DagImportFS.
+func (s *Shell) DagImportFiles(ctx context.Context, paths []string, opts ...options.DagImportOption) (*DagImportCARsOutput, error) {
+	cars := make([]*carSource, len(paths))
+	for i, path := range paths {
+		path := path
+		cars[i] = &carSource{name: path, open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}}
+	}
+	return s.dagImportCARs(ctx, cars, opts)
+}
+
+// DagImportFS imports all the files with a .car extension in fsys, walking
+// it in lexical order, in a single request. os.DirFS gives the fs.FS of a
+// directory.
+func (s *Shell) DagImportFS(ctx context.Context, fsys fs.FS, opts ...options.DagImportOption) (*DagImportCARsOutput, error) {
+	var cars []*carSource
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !strings.EqualFold(filepath.Ext(path), ".car") {
+			return nil
+		}
+		cars = append(cars, &carSource{name: path, open: func() (io.ReadCloser, error) {
+			return fsys.Open(path)
+		}})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cars) == 0 {
+		return nil, errors.New("dag import: no CAR files found")
+	}
+	return s.dagImportCARs(ctx, cars, opts)
+}
+
+// DagImportReaders imports the CARs read from readers in a single request.
+func (s *Shell) DagImportReaders(ctx context.Context, readers []io.Reader, opts ...options.DagImportOption) (*DagImportCARsOutput, error) {
+	cars := make([]*carSource, len(readers))
+	for i, r := range readers {
+		rc := io.NopCloser(r)
+		cars[i] = &carSource{open: func() (io.ReadCloser, error) {
+			return rc, nil
+		}}
+	}
+	return s.dagImportCARs(ctx, cars, opts)
+}
+
+func (s *Shell) dagImportCARs(ctx context.Context, cars []*carSource, opts []options.DagImportOption) (*DagImportCARsOutput, error) {
+	cfg, err := options.DagImportOptions(append([]options.DagImportOption{options.Dag.Stats(true)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]files.DirEntry, len(cars))
+	for i, cs := range cars {
+		cs.validate = cfg.Validate
+		defer cs.Close()
+		entries[i] = files.FileEntry(filepath.Base(cs.name), files.NewReaderFile(cs))
+	}
+	fileReader, err := s.newMultiFileReader(ctx, files.NewSliceDirectory(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.dagImport(ctx, fileReader, cfg)
+	if err != nil {
+		// the request fails with a wrapped read error
+		for _, cs := range cars {
+			if cs.err != nil {
+				return nil, cs.err
+			}
+		}
+		return nil, err
+	}
+
+	out := DagImportCARsOutput{CARs: make([]DagImportCAR, len(cars))}
+	if res != nil {
+		out.DagImportOutput = *res
+	}
+	for i, cs := range cars {
+		out.CARs[i].Name = cs.name
+		for _, r := range cs.roots {
+			out.CARs[i].Roots = append(out.CARs[i].Roots, r.String())
+		}
+	}
+	return &out, nil
+}
+
+// carSource is a CAR being imported. It is opened on the first read, and its
+// header is parsed on the way to the daemon.
+type carSource struct {
+	name     string
+	open     func() (io.ReadCloser, error)
+	validate bool
+	r        io.Reader
+	c        io.Closer
+	roots    []cid.Cid
+	err      error
+}
+
+func (cs *carSource) Read(p []byte) (int, error) {
+	if cs.err != nil {
+		return 0, cs.err
+	}
+	if cs.r == nil {
+		if err := cs.start(); err != nil {
+			cs.err = err
+			return 0, err
+		}
+	}
+	n, err := cs.r.Read(p)
+	if err != nil && err != io.EOF {
+		cs.err = err
+	}
+	return n, err
+}
+
+func (cs *carSource) start() error {
+	rc, err := cs.open()
+	if err != nil {
+		return fmt.Errorf("dag import: %w", err)
+	}
+	cs.c = rc
+
+	if cs.validate {
+		v := newCARValidator(rc, cs.name)
+		if err := v.readHeader(); err != nil {
+			return err
+		}
+		cs.roots, cs.r = v.roots, v
+		return nil
+	}
+
+	var head bytes.Buffer
+	br, err := car.NewBlockReader(io.TeeReader(rc, &head))
+	if err != nil {
+		name := cs.name
+		if name == "" {
+			name = "CAR"
+		}
+		return fmt.Errorf("dag import: %s: invalid CAR header: %w", name, err)
+	}
+	cs.roots = br.Roots
+	cs.r = io.MultiReader(&head, rc)
+	return nil
+}
+
+func (cs *carSource) Close() error {
+	if cs.c == nil {
+		return nil
+	}
+	return cs.c.Close()
+}
+
+// DagStat is the size of a single DAG.
+type DagStat struct {
+	Cid       string
+	Size      uint64
+	NumBlocks int64
+}
+
+// DagStatSummary is the size of one or more DAGs.
+type DagStatSummary struct {
+	// UniqueBlocks and TotalSize count the blocks shared by several DAGs
+	// once.
+	UniqueBlocks int
+	TotalSize    uint64
+	// SharedSize is the size of the blocks counted more than once across
+	// DagStats, and Ratio the sum of the DAG sizes over TotalSize.
+	SharedSize uint64
+	Ratio      float32
+	DagStats   []DagStat
+	// Err is set on the last summary sent by DagStatProgress if the
+	// traversal failed.
+	Err error `json:"-"`
+}
+
+// DagStat returns the sizes of the DAGs rooted at roots, CIDs or paths to
+// blocks, and their total size. All of their blocks are fetched.
+func (s *Shell) DagStat(ctx context.Context, roots ...string) (*DagStatSummary, error) {
+	var out DagStatSummary
+	if err := s.Request("dag/stat", roots...).
+		Option("progress", false).
+		Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DagStatProgress is like DagStat, but sends a summary of the blocks counted
+// so far as each block is traversed, which helps when sizing a large DAG.
+// The last summary sent before the channel is closed is the final one, with
+// UniqueBlocks, SharedSize and Ratio set.
+func (s *Shell) DagStatProgress(ctx context.Context, roots ...string) (<-chan DagStatSummary, error) {
+	resp, err := s.Request("dag/stat", roots...).
+		Option("progress", true).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan DagStatSummary)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var sum DagStatSummary
+			err := dec.Decode(&sum)
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				sum = DagStatSummary{Err: err}
+			}
+			select {
+			case out <- sum:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// DagResolve resolves path, a CID followed by a path into the DAG, to the
+// CID of the last block it reaches and the rest of the path within that
+// block.
+func (s *Shell) DagResolve(ctx context.Context, path string) (cid.Cid, string, error) {
+	var out struct {
+		Cid     cid.Cid
+		RemPath string
+	}
+	if err := s.Request("dag/resolve", path).Exec(ctx, &out); err != nil {
+		return cid.Undef, "", err
+	}
+	return out.Cid, out.RemPath, nil
+}
+
+// DagExport returns the DAG rooted at root, a CID or a path, as a CARv1
+// stream. The whole DAG is exported, so all of its blocks must be available to
+// the daemon. Cancelling ctx also aborts reading from the returned reader.
+func (s *Shell) DagExport(ctx context.Context, root string) (io.ReadCloser, error) {
+	resp, err := s.Request("dag/export", root).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	return resp.Output, nil
+}
+
+// DagExportStats describes a CAR written by DagExportTo or DagExportFile.
+type DagExportStats struct {
+	// Root is the CID of the exported DAG, as found in the CAR header.
+	Root            string
+	BlockCount      uint64
+	BlockBytesCount uint64
+}
+
+// DagExportTo writes the DAG rooted at root to w as a CARv1, like DagExport,
+// checking the CAR as it streams: its header must have a single root, which
+// must be root if that is a CID, and each block must match its CID. On error,
+// w may have received part of the CAR.
+func (s *Shell) DagExportTo(ctx context.Context, root string, w io.Writer) (*DagExportStats, error) {
+	r, err := s.DagExport(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return copyCAR(w, r, root)
+}
+
+// DagExportFile is like DagExportTo, writing the CAR to the file at path. The
+// file is only put in place once the whole CAR has been received and checked,
+// so that a failed export never leaves a truncated CAR behind; an existing
+// file is replaced.
+func (s *Shell) DagExportFile(ctx context.Context, root string, path string) (*DagExportStats, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.DagExportTo(ctx, root, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return stats, nil
+}
+
+// copyCAR copies the CARv1 exported for root from r to w, checking it on the
+// way.
+func copyCAR(w io.Writer, r io.Reader, root string) (*DagExportStats, error) {
+	br, err := car.NewBlockReader(io.TeeReader(r, w))
+	if err != nil {
+		return nil, fmt.Errorf("dag export: invalid CAR header: %w", err)
+	}
+	if br.Version != 1 {
+		return nil, fmt.Errorf("dag export: expected a CARv1, got a CARv%d", br.Version)
+	}
+	if len(br.Roots) != 1 {
+		return nil, fmt.Errorf("dag export: expected a single root, got %d", len(br.Roots))
+	}
+	if c, err := cid.Decode(root); err == nil && !br.Roots[0].Equals(c) {
+		return nil, fmt.Errorf("dag export: expected root %s, got %s", c, br.Roots[0])
+	}
+
+	stats := DagExportStats{Root: br.Roots[0].String()}
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dag export: block %d: %w", stats.BlockCount, err)
+		}
+		stats.BlockCount++
+		stats.BlockBytesCount += uint64(len(blk.RawData()))
+	}
+
+	// the end of the stream carries the error the export failed with, if any
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, err
+	}
+	return &stats, nil
 }
 
-func (s *Shell) dagToFilesReader(data interface{}) (*files.MultiFileReader, error) {
+func (s *Shell) dagToFilesReader(ctx context.Context, data interface{}) (*files.MultiFileReader, error) {
 	var r io.Reader
 	switch data := data.(type) {
 	case *files.MultiFileReader:
@@ -147,5 +679,5 @@ func (s *Shell) dagToFilesReader(data interface{}) (*files.MultiFileReader, erro
 
 	fr := files.NewReaderFile(r)
 	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
-	return s.newMultiFileReader(slf)
+	return s.newMultiFileReader(ctx, slf)
 }
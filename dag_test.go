@@ -0,0 +1,369 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+)
+
+func TestDagExport(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	root, err := s.AddDir("./testdata")
+	is.Nil(err)
+
+	r, err := s.DagExport(ctx, root)
+	is.Nil(err)
+	data, err := io.ReadAll(r)
+	is.Nil(err)
+	is.Nil(r.Close())
+
+	path := filepath.Join(t.TempDir(), "backup.car")
+	stats, err := s.DagExportFile(ctx, root, path)
+	is.Nil(err)
+	is.Equal(stats.Root, root)
+	is.True(stats.BlockCount > 1)
+	written, err := os.ReadFile(path)
+	is.Nil(err)
+	is.Equal(written, data)
+
+	// the backup round-trips through another node
+	other := shelltest.NewServer()
+	defer other.Close()
+	f, err := os.Open(path)
+	is.Nil(err)
+	defer f.Close()
+	imported, err := NewShell(other.URL()).DagImportWithOpts(f, options.Dag.Stats(true), options.Dag.Silent(false))
+	is.Nil(err)
+	is.Equal(len(imported.Roots), 1)
+	is.Equal(imported.Roots[0].Root.Cid.Value, root)
+	is.Equal(imported.Stats.BlockCount, stats.BlockCount)
+	is.Equal(imported.Stats.BlockBytesCount, stats.BlockBytesCount)
+
+	_, err = s.DagExport(ctx, "bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+	is.Err(err)
+}
+
+func TestDagExportVerify(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	ctx := context.Background()
+
+	root, err := NewShell(fake.URL()).Add(strings.NewReader("backup me"))
+	is.Nil(err)
+	var car bytes.Buffer
+	_, err = NewShell(fake.URL()).DagExportTo(ctx, root, &car)
+	is.Nil(err)
+
+	// flip the last byte of the only block
+	corrupt := append([]byte(nil), car.Bytes()...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Stream-Output", "1")
+		w.Write(corrupt)
+	}))
+	defer srv.Close()
+	s := NewShell(srv.URL)
+
+	path := filepath.Join(t.TempDir(), "backup.car")
+	_, err = s.DagExportFile(ctx, root, path)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "mismatch in content integrity"))
+	_, err = os.Stat(path)
+	is.True(os.IsNotExist(err))
+
+	_, err = NewShell(fake.URL()).DagExportTo(ctx, "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn", io.Discard)
+	is.Err(err)
+
+	// the header must be for the requested root
+	other, err := NewShell(fake.URL()).Add(strings.NewReader("something else"))
+	is.Nil(err)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(car.Bytes())
+	})
+	_, err = s.DagExportTo(ctx, other, io.Discard)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "expected root"))
+}
+
+func TestDagGetWithOpts(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	prev, err := s.DagPut(`{"n": 0}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	doc := `{"name": "second", "data": {"/": {"bytes": "aGVsbG8"}}, "prev": {"/": "` + prev + `"}, "meta": {"n": 1}}`
+	c, err := s.DagPut(doc, "dag-json", "dag-cbor")
+	is.Nil(err)
+
+	type node struct {
+		Name string  `json:"name"`
+		Data []byte  `json:"data"`
+		Prev cid.Cid `json:"prev"`
+		Meta struct {
+			N int
+		} `json:"meta"`
+	}
+	for _, codec := range []string{"dag-json", "dag-cbor"} {
+		var nd node
+		is.Nil(s.DagGetWithOptsCtx(ctx, c, &nd, options.Dag.OutputCodec(codec)))
+		is.Equal(nd.Name, "second")
+		is.Equal(nd.Data, []byte("hello"))
+		is.Equal(nd.Prev.String(), prev)
+		is.Equal(nd.Meta.N, 1)
+	}
+
+	var any map[string]interface{}
+	is.Nil(s.DagGetWithOpts(c, &any))
+	is.Equal(any["data"], []byte("hello"))
+	is.Equal(any["prev"].(cid.Cid).String(), prev)
+
+	// paths are resolved by the daemon, following links
+	var n int
+	is.Nil(s.DagGetWithOpts(c+"/meta/n", &n))
+	is.Equal(n, 1)
+	is.Nil(s.DagGetWithOpts(c+"/prev/n", &n))
+	is.Equal(n, 0)
+	var data []byte
+	is.Nil(s.DagGetWithOpts(c+"/data", &data, options.Dag.OutputCodec("raw")))
+	is.Equal(data, []byte("hello"))
+
+	raw, err := s.DagGetRaw(ctx, c, options.Dag.OutputCodec("dag-cbor"))
+	is.Nil(err)
+	block, err := s.BlockGet(c)
+	is.Nil(err)
+	is.Equal(raw, block)
+
+	err = s.DagGetWithOpts(c, &n)
+	is.Err(err)
+	err = s.DagGetWithOpts(c, &any, options.Dag.OutputCodec("dag-pb"))
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "unsupported output codec"))
+}
+
+func TestDagStat(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	leaf, err := s.DagPut(`{"leaf": true}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	a, err := s.DagPut(`{"name": "a", "child": {"/": "`+leaf+`"}}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	b, err := s.DagPut(`{"name": "b", "child": {"/": "`+leaf+`"}}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	leafBlock, err := s.BlockGet(leaf)
+	is.Nil(err)
+
+	sum, err := s.DagStat(ctx, a, b)
+	is.Nil(err)
+	is.Equal(len(sum.DagStats), 2)
+	is.Equal(sum.DagStats[0].Cid, a)
+	is.Equal(sum.DagStats[0].NumBlocks, int64(2))
+	is.Equal(sum.DagStats[1].Cid, b)
+	is.Equal(sum.UniqueBlocks, 3)
+	is.Equal(sum.SharedSize, uint64(len(leafBlock)))
+	is.Equal(sum.TotalSize, sum.DagStats[0].Size+sum.DagStats[1].Size-sum.SharedSize)
+	is.True(sum.Ratio > 1)
+
+	progress, err := s.DagStatProgress(ctx, a, b)
+	is.Nil(err)
+	var events []DagStatSummary
+	for p := range progress {
+		is.Nil(p.Err)
+		events = append(events, p)
+	}
+	// a progress event per traversed block, then the final summary
+	is.Equal(len(events), 5)
+	is.Equal(events[0].Ratio, float32(0))
+	is.Equal(events[len(events)-1].UniqueBlocks, sum.UniqueBlocks)
+	is.Equal(events[len(events)-1].TotalSize, sum.TotalSize)
+
+	_, err = s.DagStat(ctx, a+"/name")
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "root CID"))
+}
+
+func TestDagResolve(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	leaf, err := s.DagPut(`{"deep": {"value": 1}}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	root, err := s.DagPut(`{"meta": {"n": 1}, "child": {"/": "`+leaf+`"}}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+
+	for _, tc := range []struct {
+		path, cid, rem string
+	}{
+		{root, root, ""},
+		{"/ipfs/" + root + "/meta/n", root, "meta/n"},
+		{root + "/child", leaf, ""},
+		{root + "/child/deep/value", leaf, "deep/value"},
+	} {
+		c, rem, err := s.DagResolve(ctx, tc.path)
+		is.Nil(err)
+		is.Equal(c.String(), tc.cid)
+		is.Equal(rem, tc.rem)
+	}
+
+	dir, err := s.AddDir("./testdata")
+	is.Nil(err)
+	c, rem, err := s.DagResolve(ctx, dir+"/readme")
+	is.Nil(err)
+	is.NotEqual(c.String(), dir)
+	is.Equal(rem, "")
+
+	_, _, err = s.DagResolve(ctx, root+"/nope")
+	is.Err(err)
+}
+
+func TestDagPutValue(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	content, err := s.Add(strings.NewReader("payload"))
+	is.Nil(err)
+	contentCid, err := cid.Decode(content)
+	is.Nil(err)
+
+	type record struct {
+		Name     string            `json:"name"`
+		Content  cid.Cid           `json:"content"`
+		Checksum []byte            `json:"checksum"`
+		Size     int64             `json:"size"`
+		Tags     map[string]string `json:"tags,omitempty"`
+		Previous *cid.Cid          `json:"previous,omitempty"`
+	}
+	first := record{
+		Name:     "first",
+		Content:  contentCid,
+		Checksum: []byte{0xca, 0xfe},
+		Size:     7,
+		Tags:     map[string]string{"kind": "test"},
+	}
+
+	for _, codec := range []string{"dag-json", "dag-cbor"} {
+		c, err := s.DagPutValue(ctx, first, options.Dag.InputCodec(codec))
+		is.Nil(err)
+
+		var got record
+		is.Nil(s.DagGetValue(ctx, c, &got))
+		is.Equal(got, first)
+	}
+
+	// the same node as hand-written dag-json
+	c, err := s.DagPutValue(ctx, first)
+	is.Nil(err)
+	manual, err := s.DagPut(`{"name": "first", "content": {"/": "`+content+`"}, "checksum": {"/": {"bytes": "yv4"}}, "size": 7, "tags": {"kind": "test"}}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	is.Equal(c, manual)
+
+	prev, err := cid.Decode(c)
+	is.Nil(err)
+	second := record{Name: "second", Content: contentCid, Previous: &prev}
+	c, err = s.DagPutValue(ctx, second, options.Dag.StoreCodec("dag-json"))
+	is.Nil(err)
+	var name string
+	is.Nil(s.DagGetValue(ctx, c+"/previous/name", &name))
+	is.Equal(name, "first")
+
+	_, err = s.DagPutValue(ctx, first, options.Dag.InputCodec("dag-pb"))
+	is.Err(err)
+	_, err = s.DagPutValue(ctx, map[int]string{1: "one"})
+	is.Err(err)
+}
+
+func TestDagImportCARs(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	paths := []string{"./tests/cars/1.car", "./tests/cars/2.car"}
+
+	check := func(out *DagImportCARsOutput) {
+		is.Equal(len(out.CARs), 2)
+		is.Equal(len(out.Roots), 2)
+		is.Equal(out.Stats.BlockCount, 11)
+		is.Equal(out.Stats.BlockBytesCount, 411)
+		imported := make(map[string]bool)
+		for _, r := range out.Roots {
+			is.Equal(r.Root.PinErrorMsg, "")
+			imported[r.Root.Cid.Value] = true
+		}
+		for _, car := range out.CARs {
+			is.Equal(len(car.Roots), 1)
+			is.True(imported[car.Roots[0]])
+		}
+		is.NotEqual(out.CARs[0].Roots[0], out.CARs[1].Roots[0])
+	}
+
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	out, err := NewShell(fake.URL()).DagImportFiles(ctx, paths)
+	is.Nil(err)
+	check(out)
+	is.Equal(out.CARs[0].Name, paths[0])
+
+	fake = shelltest.NewServer()
+	defer fake.Close()
+	out, err = NewShell(fake.URL()).DagImportFS(ctx, os.DirFS("./tests"), options.Dag.Stats(false))
+	is.Nil(err)
+	is.Nil(out.Stats)
+	is.Equal(len(out.CARs), 3)
+	is.Equal(out.CARs[0].Name, "cars/1.car")
+	is.Equal(out.CARs[2].Name, "test.car")
+
+	fake = shelltest.NewServer()
+	defer fake.Close()
+	var readers []io.Reader
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		is.Nil(err)
+		readers = append(readers, bytes.NewReader(data))
+	}
+	out, err = NewShell(fake.URL()).DagImportReaders(ctx, readers)
+	is.Nil(err)
+	check(out)
+	is.Equal(out.CARs[1].Name, "")
+
+	s := NewShell(fake.URL())
+	_, err = s.DagImportFiles(ctx, []string{paths[0], "./tests/cars/missing.car"})
+	is.Err(err)
+	is.True(errors.Is(err, fs.ErrNotExist))
+
+	_, err = s.DagImportReaders(ctx, []io.Reader{strings.NewReader("not a CAR")})
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "invalid CAR header"))
+
+	_, err = s.DagImportFS(ctx, os.DirFS("./testdata"))
+	is.Err(err)
+}
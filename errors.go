@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Errors that can be matched with errors.Is against the errors returned by
+// Shell methods. Daemon errors are returned as *Error, failures to get a
+// response at all wrap the underlying transport error.
+var (
+	// ErrNotFound is returned when a block, path, file or name doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrNotPinned is returned when unpinning or listing something that isn't
+	// pinned.
+	ErrNotPinned = errors.New("not pinned")
+	// ErrKeyExists is returned when generating, importing or renaming a key
+	// to a name that is already taken.
+	ErrKeyExists = errors.New("key already exists")
+	// ErrCommandNotFound is returned when the daemon doesn't know the
+	// command, usually because it is too old or too new.
+	ErrCommandNotFound = errors.New("command not found")
+	// ErrDaemonUnreachable is returned when no connection to the daemon
+	// could be established.
+	ErrDaemonUnreachable = errors.New("daemon unreachable")
+	// ErrTimeout is returned when the request, or the command on the
+	// daemon, ran out of time.
+	ErrTimeout = errors.New("timeout")
+)
+
+// Is classifies daemon errors into the sentinel errors of this package. The
+// daemon only reports free-form messages, so this is based on the messages
+// Kubo returns. An unknown command only matches ErrCommandNotFound, even
+// though its message says "not found".
+func (e *Error) Is(target error) bool {
+	if e.StatusCode == http.StatusNotFound {
+		return target == ErrCommandNotFound
+	}
+	msg := e.Message
+	switch target {
+	case ErrNotFound:
+		return strings.Contains(msg, "not found") ||
+			strings.Contains(msg, "does not exist") ||
+			strings.Contains(msg, "could not find") ||
+			strings.Contains(msg, "no link named") ||
+			strings.Contains(msg, "could not resolve name")
+	case ErrNotPinned:
+		return strings.Contains(msg, "not pinned")
+	case ErrKeyExists:
+		return strings.HasPrefix(e.Command, "key/") && strings.Contains(msg, "already exists")
+	case ErrTimeout:
+		return strings.Contains(msg, context.DeadlineExceeded.Error())
+	}
+	return false
+}
+
+// transportError is a failure to get a response from the daemon. It keeps the
+// message of the underlying error, and additionally matches the sentinel
+// error classifying it.
+type transportError struct {
+	kind error
+	err  error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// wrapTransportError classifies err, as returned by http.Client.Do.
+func wrapTransportError(err error) error {
+	var kind error
+	var oerr *net.OpError
+	var uerr *url.Error
+	switch {
+	case errors.As(err, &oerr) && oerr.Op == "dial":
+		kind = ErrDaemonUnreachable
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &uerr) && uerr.Timeout():
+		kind = ErrTimeout
+	default:
+		return err
+	}
+	return &transportError{kind: kind, err: err}
+}
+
+// Logger receives diagnostics that don't make a request fail, such as a
+// malformed error response. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger diagnostics are written to. By default they are
+// discarded.
+func WithLogger(l Logger) ShellOption {
+	return func(s *Shell) {
+		s.logger = l
+	}
+}
+
+// SetLogger sets the logger diagnostics are written to, see WithLogger.
+func (s *Shell) SetLogger(l Logger) {
+	s.logger = l
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
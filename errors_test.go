@@ -0,0 +1,103 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cheekybits/is"
+)
+
+func TestDaemonErrors(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	_, err := s.Cat(fmt.Sprintf("/ipfs/%s/missing", examplesHash))
+	is.True(errors.Is(err, ErrNotFound))
+	is.False(errors.Is(err, ErrNotPinned))
+
+	var e *Error
+	is.True(errors.As(err, &e))
+	is.Equal(e.Command, "cat")
+	is.Equal(e.StatusCode, http.StatusInternalServerError)
+
+	h, err := s.Add(bytes.NewBufferString(randString(32)), Pin(false))
+	is.Nil(err)
+	err = s.Unpin(h)
+	is.True(errors.Is(err, ErrNotPinned))
+
+	name := "testErrKey" + randString(8)
+	_, err = s.KeyGen(ctx, name)
+	is.Nil(err)
+	defer s.KeyRm(ctx, name)
+	_, err = s.KeyGen(ctx, name)
+	is.True(errors.Is(err, ErrKeyExists))
+
+	err = s.Request("no/such/command").Exec(ctx, nil)
+	is.True(errors.Is(err, ErrCommandNotFound))
+	is.False(errors.Is(err, ErrNotFound))
+	is.True(errors.As(err, &e))
+	is.Equal(e.StatusCode, http.StatusNotFound)
+}
+
+func TestTransportErrors(t *testing.T) {
+	is := is.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	is.Nil(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	_, _, err = NewShell(addr).Version()
+	is.True(errors.Is(err, ErrDaemonUnreachable))
+	is.False(errors.Is(err, ErrTimeout))
+	var oerr *net.OpError
+	is.True(errors.As(err, &oerr))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := NewShell(srv.URL)
+	s.SetTimeout(10 * time.Millisecond)
+	_, _, err = s.Version()
+	is.True(errors.Is(err, ErrTimeout))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = NewShell(srv.URL).VersionCtx(ctx)
+	is.True(errors.Is(err, ErrTimeout))
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+type testLogger []string
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestLogger(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "{not json")
+	}))
+	defer srv.Close()
+
+	var logger testLogger
+	_, _, err := NewShell(srv.URL, WithLogger(&logger)).Version()
+	is.NotNil(err)
+	is.Equal(err.Error(), "version: Internal Server Error")
+	is.Equal(len(logger), 1)
+	is.Equal(logger[0], "ipfs-shell: warning! response (500) unmarshall error: invalid character 'n' looking for beginning of object key string")
+}
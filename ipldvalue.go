@@ -0,0 +1,455 @@
+package shell
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+)
+
+// Go values are mapped to the IPLD data model much like encoding/json maps
+// them to JSON: structs become maps keyed by field name, honoring json struct
+// tags, []byte becomes bytes and cid.Cid becomes a link. Values implementing
+// encoding.TextMarshaler become strings, and are decoded with
+// encoding.TextUnmarshaler. Types that can't be mapped, like those only
+// implementing json.Marshaler or structs without exported fields, are an
+// error rather than being silently dropped.
+
+var (
+	cidType             = reflect.TypeOf(cid.Cid{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// encodeValue encodes v with the given IPLD codec.
+func encodeValue(v interface{}, enc codec.Encoder) ([]byte, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := assignValue(nb, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := enc(nb.Build(), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeValue decodes data with the given IPLD codec into v, which must be a
+// non-nil pointer.
+func decodeValue(data []byte, dec codec.Decoder, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot decode into %T, a non-nil pointer is needed", v)
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dec(nb, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return loadValue(nb.Build(), rv.Elem())
+}
+
+type valueField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// valueFields lists the fields of a struct type the way encoding/json does,
+// promoted fields of embedded structs included.
+func valueFields(t reflect.Type) []valueField {
+	var fields []valueField
+	for _, f := range reflect.VisibleFields(t) {
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" || (f.Anonymous && tag == "") {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, valueField{
+			name:      name,
+			index:     f.Index,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return v.Type() == cidType && !v.Interface().(cid.Cid).Defined()
+	}
+	return v.IsZero()
+}
+
+// hasExportedFields tells whether a struct type has fields to map, as a
+// struct with only unexported fields, like time.Time, would lose its value.
+func hasExportedFields(t reflect.Type) bool {
+	if t.NumField() == 0 {
+		return true
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if f.IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+func assignValue(na datamodel.NodeAssembler, v reflect.Value) error {
+	if !v.IsValid() {
+		return na.AssignNull()
+	}
+	if v.Type() == cidType {
+		c := v.Interface().(cid.Cid)
+		if !c.Defined() {
+			return na.AssignNull()
+		}
+		return na.AssignLink(cidlink.Link{Cid: c})
+	}
+	if k := v.Kind(); k != reflect.Ptr && k != reflect.Interface {
+		pt := reflect.PtrTo(v.Type())
+		if pt.Implements(textMarshalerType) {
+			if !v.CanAddr() {
+				// make the pointer methods callable
+				addr := reflect.New(v.Type())
+				addr.Elem().Set(v)
+				v = addr.Elem()
+			}
+			text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return err
+			}
+			return na.AssignString(string(text))
+		}
+		if pt.Implements(jsonMarshalerType) {
+			return fmt.Errorf("unsupported type %s, implementing json.Marshaler but not encoding.TextMarshaler", v.Type())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return na.AssignNull()
+		}
+		return assignValue(na, v.Elem())
+	case reflect.Bool:
+		return na.AssignBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return na.AssignInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return fmt.Errorf("%d overflows the IPLD integer range", v.Uint())
+		}
+		return na.AssignInt(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return na.AssignFloat(v.Float())
+	case reflect.String:
+		return na.AssignString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return na.AssignNull()
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return na.AssignBytes(b)
+		}
+		la, err := na.BeginList(int64(v.Len()))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := assignValue(la.AssembleValue(), v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return la.Finish()
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s, keys must be strings", v.Type().Key())
+		}
+		if v.IsNil() {
+			return na.AssignNull()
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		ma, err := na.BeginMap(int64(len(keys)))
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := ma.AssembleKey().AssignString(k.String()); err != nil {
+				return err
+			}
+			if err := assignValue(ma.AssembleValue(), v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return ma.Finish()
+	case reflect.Struct:
+		if !hasExportedFields(v.Type()) {
+			return fmt.Errorf("unsupported type %s, without exported fields", v.Type())
+		}
+		fields := valueFields(v.Type())
+		ma, err := na.BeginMap(int64(len(fields)))
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || (f.omitEmpty && isEmptyValue(fv)) {
+				// nil embedded pointer, or empty
+				continue
+			}
+			if err := ma.AssembleKey().AssignString(f.name); err != nil {
+				return err
+			}
+			if err := assignValue(ma.AssembleValue(), fv); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		return ma.Finish()
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func loadValue(n datamodel.Node, v reflect.Value) error {
+	if n.IsNull() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type() == cidType {
+		l, err := n.AsLink()
+		if err != nil {
+			return err
+		}
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return fmt.Errorf("unsupported link type %T", l)
+		}
+		v.Set(reflect.ValueOf(cl.Cid))
+		return nil
+	}
+	if k := v.Kind(); k != reflect.Ptr && k != reflect.Interface {
+		pt := reflect.PtrTo(v.Type())
+		if pt.Implements(textUnmarshalerType) {
+			text, err := n.AsString()
+			if err != nil {
+				return err
+			}
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		}
+		if pt.Implements(jsonUnmarshalerType) {
+			return fmt.Errorf("unsupported type %s, implementing json.Unmarshaler but not encoding.TextUnmarshaler", v.Type())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return loadValue(n, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("cannot decode into non-empty interface %s", v.Type())
+		}
+		x, err := nodeInterface(n)
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		b, err := n.AsBool()
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := n.AsInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := n.AsInt()
+		if err != nil {
+			return err
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		v.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if n.Kind() == datamodel.Kind_Int {
+			i, err := n.AsInt()
+			v.SetFloat(float64(i))
+			return err
+		}
+		f, err := n.AsFloat()
+		v.SetFloat(f)
+		return err
+	case reflect.String:
+		s, err := n.AsString()
+		v.SetString(s)
+		return err
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && n.Kind() == datamodel.Kind_Bytes {
+			b, err := n.AsBytes()
+			if err != nil {
+				return err
+			}
+			if v.Kind() == reflect.Slice {
+				v.Set(reflect.MakeSlice(v.Type(), len(b), len(b)))
+			} else if len(b) != v.Len() {
+				return fmt.Errorf("cannot decode %d bytes into %s", len(b), v.Type())
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		if n.Kind() != datamodel.Kind_List {
+			return fmt.Errorf("cannot decode %s into %s", n.Kind(), v.Type())
+		}
+		l := int(n.Length())
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), l, l))
+		} else if l != v.Len() {
+			return fmt.Errorf("cannot decode a list of %d elements into %s", l, v.Type())
+		}
+		it := n.ListIterator()
+		for !it.Done() {
+			i, elem, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if err := loadValue(elem, v.Index(int(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s, keys must be strings", v.Type().Key())
+		}
+		if n.Kind() != datamodel.Kind_Map {
+			return fmt.Errorf("cannot decode %s into %s", n.Kind(), v.Type())
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), int(n.Length())))
+		it := n.MapIterator()
+		for !it.Done() {
+			k, elem, err := it.Next()
+			if err != nil {
+				return err
+			}
+			key, err := k.AsString()
+			if err != nil {
+				return err
+			}
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := loadValue(elem, ev); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), ev)
+		}
+		return nil
+	case reflect.Struct:
+		if !hasExportedFields(v.Type()) {
+			return fmt.Errorf("unsupported type %s, without exported fields", v.Type())
+		}
+		if n.Kind() != datamodel.Kind_Map {
+			return fmt.Errorf("cannot decode %s into %s", n.Kind(), v.Type())
+		}
+		fields := valueFields(v.Type())
+		it := n.MapIterator()
+		for !it.Done() {
+			k, elem, err := it.Next()
+			if err != nil {
+				return err
+			}
+			key, err := k.AsString()
+			if err != nil {
+				return err
+			}
+			// like encoding/json, prefer an exact match of the key
+			var field *valueField
+			for i := range fields {
+				if fields[i].name == key {
+					field = &fields[i]
+					break
+				}
+				if field == nil && strings.EqualFold(fields[i].name, key) {
+					field = &fields[i]
+				}
+			}
+			if field == nil {
+				continue
+			}
+			fv, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				// nil pointer to an embedded struct
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if err := loadValue(elem, fv); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// nodeInterface converts n to the Go value an interface{} is decoded into.
+func nodeInterface(n datamodel.Node) (interface{}, error) {
+	switch n.Kind() {
+	case datamodel.Kind_Null:
+		return nil, nil
+	case datamodel.Kind_Bool:
+		return n.AsBool()
+	case datamodel.Kind_Int:
+		return n.AsInt()
+	case datamodel.Kind_Float:
+		return n.AsFloat()
+	case datamodel.Kind_String:
+		return n.AsString()
+	case datamodel.Kind_Bytes:
+		return n.AsBytes()
+	case datamodel.Kind_Link:
+		var c cid.Cid
+		err := loadValue(n, reflect.ValueOf(&c).Elem())
+		return c, err
+	case datamodel.Kind_List:
+		var l []interface{}
+		err := loadValue(n, reflect.ValueOf(&l).Elem())
+		return l, err
+	case datamodel.Kind_Map:
+		var m map[string]interface{}
+		err := loadValue(n, reflect.ValueOf(&m).Elem())
+		return m, err
+	}
+	return nil, fmt.Errorf("unsupported kind %s", n.Kind())
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+)
+
+type valueBase struct {
+	ID uint16
+}
+
+type valueDoc struct {
+	valueBase
+	Title   string            `json:"title"`
+	Skipped string            `json:"-"`
+	Empty   string            `json:",omitempty"`
+	Parent  cid.Cid           `json:"parent,omitempty"`
+	Data    []byte            `json:"data"`
+	Scores  []float32         `json:"scores"`
+	Meta    map[string]string `json:"meta"`
+	Next    *valueDoc         `json:"next"`
+	Any     interface{}       `json:"any"`
+}
+
+func TestIPLDValue(t *testing.T) {
+	is := is.New(t)
+
+	parent, err := cid.Decode(examplesHash)
+	is.Nil(err)
+	doc := valueDoc{
+		valueBase: valueBase{ID: 7},
+		Title:     "doc",
+		Skipped:   "skipped",
+		Parent:    parent,
+		Data:      []byte{0, 1, 2},
+		Scores:    []float32{0.5, 2},
+		Meta:      map[string]string{"b": "2", "a": "1"},
+		Next:      &valueDoc{Title: "next"},
+		Any:       []interface{}{"x", int64(1)},
+	}
+
+	data, err := encodeValue(doc, dagjson.Encode)
+	is.Nil(err)
+	is.Equal(string(data), `{"ID":7,"any":["x",1],"data":{"/":{"bytes":"AAEC"}},"meta":{"a":"1","b":"2"},"next":{"ID":0,"any":null,"data":null,"meta":null,"next":null,"scores":null,"title":"next"},"parent":{"/":"`+examplesHash+`"},"scores":[0.5,2],"title":"doc"}`)
+
+	for _, codec := range []struct {
+		enc func(interface{}) ([]byte, error)
+		dec func([]byte, interface{}) error
+	}{
+		{CBOREncoder.Encode, CBOREncoder.Decode},
+		{
+			func(v interface{}) ([]byte, error) { return encodeValue(v, dagjson.Encode) },
+			func(data []byte, v interface{}) error { return decodeValue(data, dagjson.Decode, v) },
+		},
+	} {
+		data, err := codec.enc(doc)
+		is.Nil(err)
+		var decoded valueDoc
+		is.Nil(codec.dec(data, &decoded))
+		expected := doc
+		expected.Skipped = ""
+		is.Equal(decoded, expected)
+	}
+
+	data, err = encodeValue(map[string]interface{}{"n": int64(-1)}, dagcbor.Encode)
+	is.Nil(err)
+	var small struct{ N uint8 }
+	is.Err(decodeValue(data, dagcbor.Decode, &small))
+	is.Err(decodeValue(data, dagcbor.Decode, small))
+}
+
+func TestIPLDValueText(t *testing.T) {
+	is := is.New(t)
+
+	type event struct {
+		At   time.Time  `json:"at"`
+		Addr net.IP     `json:"addr"`
+		Next *time.Time `json:"next"`
+	}
+	at := time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC)
+	ev := event{At: at, Addr: net.IPv4(10, 0, 0, 1)}
+
+	data, err := encodeValue(ev, dagjson.Encode)
+	is.Nil(err)
+	is.Equal(string(data), `{"addr":"10.0.0.1","at":"2023-05-01T12:30:00Z","next":null}`)
+
+	data, err = CBOREncoder.Encode(ev)
+	is.Nil(err)
+	var decoded event
+	is.Nil(CBOREncoder.Decode(data, &decoded))
+	is.True(decoded.At.Equal(at))
+	is.True(decoded.Addr.Equal(ev.Addr))
+	is.Nil(decoded.Next)
+
+	// types that would lose their value are an error
+	_, err = CBOREncoder.Encode(struct{ Raw json.RawMessage }{json.RawMessage(`1`)})
+	is.Err(err)
+	_, err = CBOREncoder.Encode(struct{ Hidden struct{ n int } }{})
+	is.Err(err)
+	is.Err(decodeValue(data, dagcbor.Decode, &struct{ At struct{ n int } }{}))
+}
@@ -12,6 +12,11 @@ type PublishResponse struct {
 
 // Publish updates a mutable name to point to a given value
 func (s *Shell) Publish(node string, value string) error {
+	return s.PublishCtx(context.Background(), node, value)
+}
+
+// PublishCtx is like Publish but with a context.
+func (s *Shell) PublishCtx(ctx context.Context, node string, value string) error {
 	var pubResp PublishResponse
 	req := s.Request("name/publish")
 	if node != "" {
@@ -19,11 +24,16 @@ func (s *Shell) Publish(node string, value string) error {
 	}
 	req.Arguments(value)
 
-	return req.Exec(context.Background(), &pubResp)
+	return req.Exec(ctx, &pubResp)
 }
 
 // PublishWithDetails is used for fine grained control over record publishing
 func (s *Shell) PublishWithDetails(contentHash, key string, lifetime, ttl time.Duration, resolve bool) (*PublishResponse, error) {
+	return s.PublishWithDetailsCtx(context.Background(), contentHash, key, lifetime, ttl, resolve)
+}
+
+// PublishWithDetailsCtx is like PublishWithDetails but with a context.
+func (s *Shell) PublishWithDetailsCtx(ctx context.Context, contentHash, key string, lifetime, ttl time.Duration, resolve bool) (*PublishResponse, error) {
 	var pubResp PublishResponse
 	req := s.Request("name/publish", contentHash).Option("resolve", resolve)
 	if key != "" {
@@ -35,7 +45,7 @@ func (s *Shell) PublishWithDetails(contentHash, key string, lifetime, ttl time.D
 	if ttl.Seconds() > 0 {
 		req.Option("ttl", ttl)
 	}
-	err := req.Exec(context.Background(), &pubResp)
+	err := req.Exec(ctx, &pubResp)
 	if err != nil {
 		return nil, err
 	}
@@ -45,11 +55,16 @@ func (s *Shell) PublishWithDetails(contentHash, key string, lifetime, ttl time.D
 // Resolve gets resolves the string provided to an /ipns/[name]. If asked to
 // resolve an empty string, resolve instead resolves the node's own /ipns value.
 func (s *Shell) Resolve(id string) (string, error) {
+	return s.ResolveCtx(context.Background(), id)
+}
+
+// ResolveCtx is like Resolve but with a context.
+func (s *Shell) ResolveCtx(ctx context.Context, id string) (string, error) {
 	req := s.Request("name/resolve")
 	if id != "" {
 		req.Arguments(id)
 	}
 	var out struct{ Path string }
-	err := req.Exec(context.Background(), &out)
+	err := req.Exec(ctx, &out)
 	return out.Path, err
 }
@@ -118,7 +118,7 @@ func (keyImportOpt) AllowAnyKeyType(allow bool) KeyImportOpt {
 func (s *Shell) KeyImport(ctx context.Context, name string, key io.Reader, options ...KeyImportOpt) error {
 	fr := files.NewReaderFile(key)
 	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
-	fileReader, err := s.newMultiFileReader(slf)
+	fileReader, err := s.newMultiFileReader(ctx, slf)
 	if err != nil {
 		return err
 	}
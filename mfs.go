@@ -338,7 +338,7 @@ func (s *Shell) FilesStat(ctx context.Context, path string, options ...FilesOpt)
 func (s *Shell) FilesWrite(ctx context.Context, path string, data io.Reader, options ...FilesOpt) error {
 	fr := files.NewReaderFile(data)
 	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
-	fileReader, err := s.newMultiFileReader(slf)
+	fileReader, err := s.newMultiFileReader(ctx, slf)
 	if err != nil {
 		return err
 	}
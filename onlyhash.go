@@ -0,0 +1,240 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	files "github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ComputeCID returns the CID that adding r with the given options would
+// produce, like Add with OnlyHash(true), without contacting the daemon.
+//
+// The options that affect the DAG are honored: chunker, trickle, raw leaves,
+// CID version, hash, inlining and wrapping. Options that only matter to the
+// daemon, like pinning, are ignored. Mode and mtime are not supported.
+func ComputeCID(r io.Reader, options ...AddOpts) (string, error) {
+	rb := &RequestBuilder{command: "add"}
+	if _, err := applyAddOpts(rb, options); err != nil {
+		return "", err
+	}
+	return computeCID(rb.opts, "", files.NewReaderFile(r))
+}
+
+// ComputeDirCID returns the CID that adding dir with the given options would
+// produce, like AddDir with OnlyHash(true), without contacting the daemon.
+// See ComputeCID.
+func ComputeDirCID(dir string, options ...AddOpts) (string, error) {
+	rb := &RequestBuilder{command: "add"}
+	filter, err := applyAddOpts(rb, options)
+	if err != nil {
+		return "", err
+	}
+
+	sf, err := filter.open(dir)
+	if err != nil {
+		return "", err
+	}
+	defer sf.Close()
+
+	return computeCID(rb.opts, filepath.Base(dir), sf)
+}
+
+// inlineBuilder builds identity CIDs for blocks of up to limit bytes.
+type inlineBuilder struct {
+	cid.Builder
+	limit int
+}
+
+func (b inlineBuilder) Sum(data []byte) (cid.Cid, error) {
+	if len(data) > b.limit {
+		return b.Builder.Sum(data)
+	}
+	return cid.V1Builder{Codec: b.GetCodec(), MhType: mh.IDENTITY}.Sum(data)
+}
+
+func (b inlineBuilder) WithCodec(c uint64) cid.Builder {
+	return inlineBuilder{b.Builder.WithCodec(c), b.limit}
+}
+
+// localAdder builds UnixFS DAGs the way the daemon's add does.
+type localAdder struct {
+	ctx       context.Context
+	dag       ipld.DAGService
+	builder   cid.Builder
+	chunker   string
+	rawLeaves bool
+	trickle   bool
+}
+
+// computeCID adds the node under the given name with the settings of an add
+// request.
+func computeCID(opts map[string]string, name string, n files.Node) (string, error) {
+	for _, unsupported := range []string{"mode", "mtime", "preserve-mode", "preserve-mtime"} {
+		if v, ok := opts[unsupported]; ok && v != "false" {
+			return "", fmt.Errorf("computing CIDs with the %s option is not supported", unsupported)
+		}
+	}
+
+	boolOpt := func(name string) (bool, bool) {
+		v, ok := opts[name]
+		return v == "true", ok
+	}
+
+	version := -1
+	if v, ok := opts["cid-version"]; ok {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			return "", fmt.Errorf("invalid CID version %q: %w", v, err)
+		}
+	}
+	hash := uint64(mh.SHA2_256)
+	if v, ok := opts["hash"]; ok {
+		hash = mh.Names[strings.ToLower(v)]
+	}
+	if hash != mh.SHA2_256 && version == -1 {
+		version = 1
+	}
+	if version == -1 {
+		version = 0
+	}
+
+	prefix, err := dag.PrefixForCidVersion(version)
+	if err != nil {
+		return "", err
+	}
+	prefix.MhType = hash
+	prefix.MhLength = -1
+
+	var builder cid.Builder = prefix
+	if inline, _ := boolOpt("inline"); inline {
+		limit := 32
+		if v, ok := opts["inline-limit"]; ok {
+			if limit, err = strconv.Atoi(v); err != nil {
+				return "", fmt.Errorf("invalid inline limit %q: %w", v, err)
+			}
+		}
+		builder = inlineBuilder{builder, limit}
+	}
+
+	rawLeaves, set := boolOpt("raw-leaves")
+	if !set {
+		nocopy, _ := boolOpt("nocopy")
+		rawLeaves = version > 0 || nocopy
+	}
+	trickle, _ := boolOpt("trickle")
+
+	// Like the daemon with --only-hash, throw all blocks away.
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewNullDatastore()))
+	a := &localAdder{
+		ctx:       context.Background(),
+		dag:       dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		builder:   builder,
+		chunker:   opts["chunker"],
+		rawLeaves: rawLeaves,
+		trickle:   trickle,
+	}
+
+	var root ipld.Node
+	if wrap, _ := boolOpt("wrap-with-directory"); wrap {
+		root, err = a.addDir(files.NewSliceDirectory([]files.DirEntry{files.FileEntry(name, n)}))
+	} else {
+		root, err = a.addNode(n)
+	}
+	if err != nil {
+		return "", err
+	}
+	return root.Cid().String(), nil
+}
+
+func (a *localAdder) addNode(n files.Node) (ipld.Node, error) {
+	switch n := n.(type) {
+	case files.Directory:
+		return a.addDir(n)
+	case *files.Symlink:
+		data, err := ft.SymlinkData(n.Target)
+		if err != nil {
+			return nil, err
+		}
+		nd := dag.NodeWithData(data)
+		if err := nd.SetCidBuilder(a.builder); err != nil {
+			return nil, err
+		}
+		return nd, a.dag.Add(a.ctx, nd)
+	case files.File:
+		return a.addFile(n)
+	default:
+		return nil, fmt.Errorf("unrecognized file type %T", n)
+	}
+}
+
+func (a *localAdder) addFile(f files.File) (ipld.Node, error) {
+	chnk, err := chunker.FromString(f, a.chunker)
+	if err != nil {
+		return nil, err
+	}
+
+	params := ihelper.DagBuilderParams{
+		Dagserv:    a.dag,
+		RawLeaves:  a.rawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: a.builder,
+	}
+	db, err := params.New(chnk)
+	if err != nil {
+		return nil, err
+	}
+	if a.trickle {
+		return trickle.Layout(db)
+	}
+	return balanced.Layout(db)
+}
+
+func (a *localAdder) addDir(d files.Directory) (ipld.Node, error) {
+	dir := uio.NewDirectory(a.dag)
+	dir.SetCidBuilder(a.builder)
+
+	it := d.Entries()
+	for it.Next() {
+		child, err := a.addNode(it.Node())
+		if err != nil {
+			return nil, err
+		}
+		// The daemon links unnamed files, like wrapped readers, by their CID.
+		name := it.Name()
+		if name == "" {
+			name = child.Cid().String()
+		}
+		if err := dir.AddChild(a.ctx, name, child); err != nil {
+			return nil, err
+		}
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return nd, a.dag.Add(a.ctx, nd)
+}
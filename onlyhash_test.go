@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cheekybits/is"
+)
+
+func TestComputeCID(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+
+	data := []byte(randString(600 * 1024))
+	for _, opts := range [][]AddOpts{
+		nil,
+		{CidVersion(1)},
+		{Hash("sha3-256")},
+		{RawLeaves(true)},
+		{Chunker("size-1000"), Trickle(true)},
+		{Chunker("rabin-2048-65536-131072")},
+		{CidVersion(1), Inline(true), InlineLimit(64)},
+	} {
+		expected, err := s.Add(bytes.NewReader(data), append(opts, OnlyHash(true))...)
+		is.Nil(err)
+		actual, err := ComputeCID(bytes.NewReader(data), opts...)
+		is.Nil(err)
+		is.Equal(actual, expected)
+	}
+
+	events, err := s.AddStream(context.Background(), bytes.NewReader(data), WrapWithDirectory(true), OnlyHash(true))
+	is.Nil(err)
+	var root AddEvent
+	for ev := range events {
+		is.Nil(ev.Err)
+		root = ev
+	}
+	mhash, err := ComputeCID(bytes.NewReader(data), WrapWithDirectory(true))
+	is.Nil(err)
+	is.Equal(mhash, root.Hash)
+
+	mhash, err = ComputeCID(bytes.NewBufferString("Hello IPFS Shell tests"))
+	is.Nil(err)
+	is.Equal(mhash, "QmUfZ9rAdhV5ioBzXKdUTh2ZNsz9bzbkaLVyQ8uc8pj21F")
+
+	_, err = ComputeCID(bytes.NewReader(data), Hash("nope"))
+	is.NotNil(err)
+}
+
+func TestComputeDirCID(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+
+	mhash, err := ComputeDirCID("./testdata")
+	is.Nil(err)
+	is.Equal(mhash, examplesHash)
+
+	for _, opts := range [][]AddOpts{
+		{CidVersion(1)},
+		{Hash("blake2b-256"), WrapWithDirectory(true)},
+		{Ignore("ping", "security-*"), Hidden(true)},
+	} {
+		expected, err := s.AddDir("./testdata", append(opts, OnlyHash(true))...)
+		is.Nil(err)
+		actual, err := ComputeDirCID("./testdata", opts...)
+		is.Nil(err)
+		is.Equal(actual, expected)
+	}
+}
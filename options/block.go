@@ -0,0 +1,78 @@
+package options
+
+type blockOpts struct{}
+
+var Block blockOpts
+
+// BlockPutSettings is a set of Block.Put options.
+type BlockPutSettings struct {
+	CidCodec      string
+	MhType        string
+	MhLength      int
+	Pin           bool
+	AllowBigBlock bool
+}
+
+// BlockPutOption is a single Block.Put option.
+type BlockPutOption func(opts *BlockPutSettings) error
+
+// BlockPutOptions applies the given options to a BlockPutSettings instance.
+func BlockPutOptions(opts ...BlockPutOption) (*BlockPutSettings, error) {
+	options := &BlockPutSettings{
+		CidCodec:      "raw",
+		MhType:        "sha2-256",
+		MhLength:      -1,
+		Pin:           false,
+		AllowBigBlock: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// CidCodec is an option for Block.Put which specifies the multicodec of the
+// returned CIDs, like "dag-pb" or "dag-cbor".
+// Default is "raw".
+func (blockOpts) CidCodec(codec string) BlockPutOption {
+	return func(opts *BlockPutSettings) error {
+		opts.CidCodec = codec
+		return nil
+	}
+}
+
+// Hash is an option for Block.Put which specifies the multihash function and
+// length used to hash the blocks. A length of -1 is the default length of the
+// function.
+// Default is "sha2-256" with length -1.
+func (blockOpts) Hash(mhType string, mhLen int) BlockPutOption {
+	return func(opts *BlockPutSettings) error {
+		opts.MhType = mhType
+		opts.MhLength = mhLen
+		return nil
+	}
+}
+
+// Pin is an option for Block.Put which specifies whether to pin the blocks
+// recursively once added.
+// Default is false.
+func (blockOpts) Pin(pin bool) BlockPutOption {
+	return func(opts *BlockPutSettings) error {
+		opts.Pin = pin
+		return nil
+	}
+}
+
+// AllowBigBlock is an option for Block.Put which disables the block size
+// check, allowing blocks over 1MiB which can't be exchanged with other peers.
+// Default is false.
+func (blockOpts) AllowBigBlock(allowBigBlock bool) BlockPutOption {
+	return func(opts *BlockPutSettings) error {
+		opts.AllowBigBlock = allowBigBlock
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+package options
+
+import "fmt"
+
+// DagGetSettings is a set of DagGet options.
+type DagGetSettings struct {
+	OutputCodec string
+}
+
+// DagGetOption is a single DagGet option.
+type DagGetOption func(opts *DagGetSettings) error
+
+// DagGetOptions applies the given options to a DagGetSettings instance.
+func DagGetOptions(opts ...DagGetOption) (*DagGetSettings, error) {
+	options := &DagGetSettings{
+		OutputCodec: "dag-json",
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// OutputCodec is an option for Dag.Get which specifies the codec the node is
+// sent with: "dag-json", "dag-cbor", or "raw" for a node that is bytes.
+// Default is "dag-json".
+func (dagOpts) OutputCodec(codec string) DagGetOption {
+	return func(opts *DagGetSettings) error {
+		switch codec {
+		case "dag-json", "dag-cbor", "raw":
+			opts.OutputCodec = codec
+			return nil
+		}
+		return fmt.Errorf("unsupported output codec %q", codec)
+	}
+}
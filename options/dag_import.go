@@ -6,6 +6,7 @@ type DagImportSettings struct {
 	Silent        bool
 	Stats         bool
 	AllowBigBlock bool
+	Validate      bool
 }
 
 // DagImportOption is a single DagImport option.
@@ -18,6 +19,7 @@ func DagImportOptions(opts ...DagImportOption) (*DagImportSettings, error) {
 		Silent:        false,
 		Stats:         false,
 		AllowBigBlock: false,
+		Validate:      false,
 	}
 
 	for _, opt := range opts {
@@ -70,3 +72,15 @@ func (dagOpts) AllowBigBlock(allowBigBlock bool) DagImportOption {
 		return nil
 	}
 }
+
+// Validate is an option for Dag.Import which specifies whether to check the
+// CARs on the client as they are sent: headers must be valid CARv1 or CARv2
+// headers, blocks must match their CIDs and the roots must be among the
+// blocks. The import fails at the first invalid block, before it is sent.
+// Default is false.
+func (dagOpts) Validate(validate bool) DagImportOption {
+	return func(opts *DagImportSettings) error {
+		opts.Validate = validate
+		return nil
+	}
+}
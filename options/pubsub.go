@@ -0,0 +1,81 @@
+package options
+
+import (
+	"fmt"
+	"time"
+)
+
+type pubsubOpts struct{}
+
+var PubSub pubsubOpts
+
+// PubSubGapFunc is called when a subscription is restored after it was
+// interrupted. Messages published between since and until may have been
+// missed; cause is the error that interrupted the subscription.
+type PubSubGapFunc func(since, until time.Time, cause error)
+
+// PubSubSubscribeSettings is a set of PubSub.Subscribe options.
+type PubSubSubscribeSettings struct {
+	Resubscribe bool
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	OnGap       PubSubGapFunc
+}
+
+// PubSubSubscribeOption is a single PubSub.Subscribe option.
+type PubSubSubscribeOption func(opts *PubSubSubscribeSettings) error
+
+// PubSubSubscribeOptions applies the given options to a
+// PubSubSubscribeSettings instance.
+func PubSubSubscribeOptions(opts ...PubSubSubscribeOption) (*PubSubSubscribeSettings, error) {
+	options := &PubSubSubscribeSettings{
+		Resubscribe: false,
+		MinBackoff:  100 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// Resubscribe is an option for PubSub.Subscribe which specifies whether to
+// subscribe again, with backoff, when the subscription is interrupted, for
+// instance by a daemon restart. The subscription still ends on errors that
+// subscribing again wouldn't fix, like an undecodable message or pubsub being
+// disabled on the daemon. Default is false.
+func (pubsubOpts) Resubscribe(resubscribe bool) PubSubSubscribeOption {
+	return func(opts *PubSubSubscribeSettings) error {
+		opts.Resubscribe = resubscribe
+		return nil
+	}
+}
+
+// Backoff is an option for PubSub.Subscribe which specifies how long to wait
+// between attempts to subscribe again. The delay starts at min and doubles up
+// to max. Default is 100ms to 30s.
+func (pubsubOpts) Backoff(min, max time.Duration) PubSubSubscribeOption {
+	return func(opts *PubSubSubscribeSettings) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("invalid backoff range [%s, %s]", min, max)
+		}
+		opts.MinBackoff = min
+		opts.MaxBackoff = max
+		return nil
+	}
+}
+
+// OnGap is an option for PubSub.Subscribe which specifies a function called
+// each time the subscription is restored, reporting the gap during which
+// messages may have been missed. It implies Resubscribe(true).
+func (pubsubOpts) OnGap(f PubSubGapFunc) PubSubSubscribeOption {
+	return func(opts *PubSubSubscribeSettings) error {
+		opts.Resubscribe = true
+		opts.OnGap = f
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package options
+
+type repoOpts struct{}
+
+var Repo repoOpts
+
+// RepoGCSettings is a set of Repo.GC options.
+type RepoGCSettings struct {
+	StreamErrors bool
+	Silent       bool
+}
+
+// RepoGCOption is a single Repo.GC option.
+type RepoGCOption func(opts *RepoGCSettings) error
+
+// RepoGCOptions applies the given options to a RepoGCSettings instance.
+func RepoGCOptions(opts ...RepoGCOption) (*RepoGCSettings, error) {
+	options := &RepoGCSettings{
+		StreamErrors: false,
+		Silent:       false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// StreamErrors is an option for Repo.GC which specifies whether to report
+// the blocks that couldn't be removed and carry on, instead of stopping at
+// the first error. The GC then fails once it is done.
+// Default is false.
+func (repoOpts) StreamErrors(streamErrors bool) RepoGCOption {
+	return func(opts *RepoGCSettings) error {
+		opts.StreamErrors = streamErrors
+		return nil
+	}
+}
+
+// Silent is an option for Repo.GC which specifies whether to omit the keys
+// of the removed blocks, only reporting errors.
+// Default is false.
+func (repoOpts) Silent(silent bool) RepoGCOption {
+	return func(opts *RepoGCSettings) error {
+		opts.Silent = silent
+		return nil
+	}
+}
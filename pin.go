@@ -0,0 +1,196 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+type PinOpt func(*RequestBuilder) error
+type pinOpts struct{}
+
+var PinOpts pinOpts
+
+// Name sets the name of the pins created by PinCtx. For PinsOfType and
+// PinsStream, it selects the pins whose name contains name, and implies
+// Names(true).
+func (pinOpts) Name(name string) PinOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("name", name)
+		return nil
+	}
+}
+
+// Names makes PinsOfType and PinsStream report the names of the pins, which
+// is slower.
+func (pinOpts) Names(enabled bool) PinOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("names", enabled)
+		return nil
+	}
+}
+
+// Recursive sets whether PinCtx pins the whole DAG or only its root. Default
+// true.
+func (pinOpts) Recursive(enabled bool) PinOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("recursive", enabled)
+		return nil
+	}
+}
+
+func applyPinOpts(rb *RequestBuilder, options []PinOpt) (*RequestBuilder, error) {
+	for _, opt := range options {
+		if err := opt(rb); err != nil {
+			return nil, err
+		}
+	}
+	return rb, nil
+}
+
+// PinUpdate pins to, which must be a derivative of from, by only fetching
+// the parts of to that differ from from. from must be pinned recursively. If
+// unpin is true, from is unpinned afterwards.
+func (s *Shell) PinUpdate(from, to string, unpin bool) error {
+	return s.PinUpdateCtx(context.Background(), from, to, unpin)
+}
+
+// PinUpdateCtx is like PinUpdate but with a context.
+func (s *Shell) PinUpdateCtx(ctx context.Context, from, to string, unpin bool) error {
+	return s.Request("pin/update", from, to).
+		Option("unpin", unpin).
+		Exec(ctx, nil)
+}
+
+// PinBadNode is a block of a pinned DAG that couldn't be verified.
+type PinBadNode struct {
+	Cid string
+	Err string
+}
+
+// PinVerifyResult is the result of verifying a recursive pin.
+type PinVerifyResult struct {
+	Cid      string
+	Ok       bool
+	BadNodes []PinBadNode
+
+	// Err is set on the last result sent by PinVerify if verification
+	// failed.
+	Err error
+}
+
+// PinVerify checks that the DAGs of all recursive pins are complete and
+// sends the result for each broken pin. If verbose is true, the pins that
+// are fine are sent too. The channel is closed once all pins are checked.
+func (s *Shell) PinVerify(ctx context.Context, verbose bool) (<-chan PinVerifyResult, error) {
+	resp, err := s.Request("pin/verify").
+		Option("verbose", verbose).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan PinVerifyResult)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var raw struct {
+				Cid      string
+				Ok       bool
+				BadNodes []PinBadNode
+				Err      string
+			}
+			var res PinVerifyResult
+			if err := dec.Decode(&raw); err == io.EOF {
+				return
+			} else if err != nil {
+				res.Err = err
+			} else if raw.Err != "" {
+				res.Err = errors.New(raw.Err)
+			} else {
+				res = PinVerifyResult{Cid: raw.Cid, Ok: raw.Ok, BadNodes: raw.BadNodes}
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+			if res.Err != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PinAddEvent is an event of PinAdd.
+type PinAddEvent struct {
+	// Progress is the number of blocks fetched so far, on progress events.
+	Progress int
+	// Pins are the CIDs pinned, on the last event of a successful pin.
+	Pins []string
+	// Err is set, and all other fields are empty, on the last event of a
+	// failed pin.
+	Err error
+}
+
+// PinAdd pins path like PinCtx, reporting the blocks fetched so far as the
+// DAG is walked. The channel is closed after the event carrying the pinned
+// CIDs, or after an event carrying the error the pin failed with.
+// Cancelling ctx aborts the pin and closes the channel.
+func (s *Shell) PinAdd(ctx context.Context, path string, options ...PinOpt) (<-chan PinAddEvent, error) {
+	rb, err := applyPinOpts(s.Request("pin/add", path).
+		Option("recursive", true).
+		Option("progress", true), options)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rb.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan PinAddEvent)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var raw struct {
+				Pins     []string
+				Progress int
+			}
+			var ev PinAddEvent
+			if err := dec.Decode(&raw); err == io.EOF {
+				ev.Err = errors.New("no results received")
+			} else if err != nil {
+				ev.Err = err
+			} else if raw.Pins == nil {
+				ev.Progress = raw.Progress
+			} else {
+				ev.Pins = raw.Pins
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+			if ev.Err != nil || ev.Pins != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
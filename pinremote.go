@@ -0,0 +1,222 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RemotePinStatus is the status of a pin on a remote pinning service.
+type RemotePinStatus string
+
+const (
+	RemotePinQueued  RemotePinStatus = "queued"
+	RemotePinPinning RemotePinStatus = "pinning"
+	RemotePinPinned  RemotePinStatus = "pinned"
+	RemotePinFailed  RemotePinStatus = "failed"
+)
+
+func (st RemotePinStatus) valid() bool {
+	switch st {
+	case RemotePinQueued, RemotePinPinning, RemotePinPinned, RemotePinFailed:
+		return true
+	}
+	return false
+}
+
+// RemotePin is a pin on a remote pinning service.
+type RemotePin struct {
+	Status RemotePinStatus
+	Cid    string
+	Name   string
+
+	// Err is set on the last pin sent by PinRemoteLs if listing failed.
+	Err error `json:"-"`
+}
+
+// RemotePinService is a remote pinning service configured on the node.
+type RemotePinService struct {
+	Service     string
+	ApiEndpoint string
+	// Stat is only set by PinRemoteServiceLs when asked for.
+	Stat *RemotePinServiceStat `json:",omitempty"`
+}
+
+// RemotePinServiceStat reports whether a remote pinning service could be
+// reached, and its pin counts if so.
+type RemotePinServiceStat struct {
+	// Status is either "valid" or "invalid".
+	Status   string
+	PinCount *RemotePinCount `json:",omitempty"`
+}
+
+// RemotePinCount is the number of pins in each status on a remote pinning
+// service.
+type RemotePinCount struct {
+	Queued  int
+	Pinning int
+	Pinned  int
+	Failed  int
+}
+
+type PinRemoteOpt func(*RequestBuilder) error
+type pinRemoteOpt struct{}
+
+var PinRemote pinRemoteOpt
+
+// Name sets the name of the pin created by PinRemoteAdd. For PinRemoteLs and
+// PinRemoteRm, it selects the pins with that name.
+func (pinRemoteOpt) Name(name string) PinRemoteOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("name", name)
+		return nil
+	}
+}
+
+// Cid selects the pins of the given CIDs, for PinRemoteLs and PinRemoteRm.
+func (pinRemoteOpt) Cid(cids ...string) PinRemoteOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("cid", cids)
+		return nil
+	}
+}
+
+// Status selects the pins in the given statuses, for PinRemoteLs and
+// PinRemoteRm. Only pinned pins are selected by default.
+func (pinRemoteOpt) Status(statuses ...RemotePinStatus) PinRemoteOpt {
+	return func(rb *RequestBuilder) error {
+		values := make([]string, len(statuses))
+		for i, st := range statuses {
+			if !st.valid() {
+				return fmt.Errorf("status %q is not valid", st)
+			}
+			values[i] = string(st)
+		}
+		rb.Option("status", values)
+		return nil
+	}
+}
+
+// Background makes PinRemoteAdd return as soon as the pin is queued by the
+// service, instead of waiting for it to be pinned.
+func (pinRemoteOpt) Background(enabled bool) PinRemoteOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("background", enabled)
+		return nil
+	}
+}
+
+// Force allows PinRemoteRm to remove several pins at once.
+func (pinRemoteOpt) Force(enabled bool) PinRemoteOpt {
+	return func(rb *RequestBuilder) error {
+		rb.Option("force", enabled)
+		return nil
+	}
+}
+
+func (s *Shell) pinRemoteRequest(command, service string, options []PinRemoteOpt, args ...string) (*RequestBuilder, error) {
+	rb := s.Request(command, args...).Option("service", service)
+	for _, opt := range options {
+		if err := opt(rb); err != nil {
+			return nil, err
+		}
+	}
+	return rb, nil
+}
+
+// PinRemoteAdd pins path on the remote pinning service named service. Unless
+// the Background option is given, it waits for the pin to be pinned.
+func (s *Shell) PinRemoteAdd(ctx context.Context, service, path string, options ...PinRemoteOpt) (*RemotePin, error) {
+	rb, err := s.pinRemoteRequest("pin/remote/add", service, options, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var out RemotePin
+	if err := rb.Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PinRemoteLs lists the pins on the remote pinning service named service,
+// as selected by the Name, Cid and Status options. The channel is closed
+// once all pins are sent. If listing fails midway, the last pin sent only
+// carries the error in Err.
+func (s *Shell) PinRemoteLs(ctx context.Context, service string, options ...PinRemoteOpt) (<-chan RemotePin, error) {
+	rb, err := s.pinRemoteRequest("pin/remote/ls", service, options)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rb.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan RemotePin)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var pin RemotePin
+			if err := dec.Decode(&pin); err != nil {
+				if err == io.EOF {
+					return
+				}
+				pin = RemotePin{Err: err}
+			}
+			select {
+			case out <- pin:
+			case <-ctx.Done():
+				return
+			}
+			if pin.Err != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PinRemoteRm removes the pins on the remote pinning service named service,
+// as selected by the Name, Cid and Status options. Removing more than one pin
+// requires the Force option.
+func (s *Shell) PinRemoteRm(ctx context.Context, service string, options ...PinRemoteOpt) error {
+	rb, err := s.pinRemoteRequest("pin/remote/rm", service, options)
+	if err != nil {
+		return err
+	}
+	return rb.Exec(ctx, nil)
+}
+
+// PinRemoteServiceAdd configures a remote pinning service under name, given
+// the endpoint of its pinning service API and the key to access it.
+func (s *Shell) PinRemoteServiceAdd(ctx context.Context, name, endpoint, key string) error {
+	return s.Request("pin/remote/service/add", name, endpoint, key).Exec(ctx, nil)
+}
+
+// PinRemoteServiceLs lists the remote pinning services configured on the
+// node, sorted by name. If stat is true, every service is asked for its pin
+// counts.
+func (s *Shell) PinRemoteServiceLs(ctx context.Context, stat bool) ([]RemotePinService, error) {
+	var out struct{ RemoteServices []RemotePinService }
+	if err := s.Request("pin/remote/service/ls").
+		Option("stat", stat).
+		Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out.RemoteServices, nil
+}
+
+// PinRemoteServiceRm removes the remote pinning service named name. Removing
+// an unknown service isn't an error.
+func (s *Shell) PinRemoteServiceRm(ctx context.Context, name string) error {
+	return s.Request("pin/remote/service/rm", name).Exec(ctx, nil)
+}
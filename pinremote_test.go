@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+)
+
+func TestPinRemoteService(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	is.Nil(s.PinRemoteServiceAdd(ctx, "b", "https://pins.example.com/api/", "secret"))
+	is.Nil(s.PinRemoteServiceAdd(ctx, "a", "https://other.example.com", "secret"))
+	is.NotNil(s.PinRemoteServiceAdd(ctx, "a", "https://other.example.com", "secret"))
+	is.NotNil(s.PinRemoteServiceAdd(ctx, "c", "ftp://pins.example.com", "secret"))
+	is.NotNil(s.PinRemoteServiceAdd(ctx, "c", "https://pins.example.com/pins", "secret"))
+
+	services, err := s.PinRemoteServiceLs(ctx, false)
+	is.Nil(err)
+	is.Equal(services, []RemotePinService{
+		{Service: "a", ApiEndpoint: "https://other.example.com"},
+		{Service: "b", ApiEndpoint: "https://pins.example.com/api"},
+	})
+
+	c, err := s.Add(strings.NewReader("queued"))
+	is.Nil(err)
+	_, err = s.PinRemoteAdd(ctx, "b", c, PinRemote.Background(true))
+	is.Nil(err)
+	services, err = s.PinRemoteServiceLs(ctx, true)
+	is.Nil(err)
+	is.Equal(len(services), 2)
+	is.Equal(*services[1].Stat, RemotePinServiceStat{
+		Status:   "valid",
+		PinCount: &RemotePinCount{Queued: 1},
+	})
+
+	is.Nil(s.PinRemoteServiceRm(ctx, "a"))
+	is.Nil(s.PinRemoteServiceRm(ctx, "a"))
+	services, err = s.PinRemoteServiceLs(ctx, false)
+	is.Nil(err)
+	is.Equal(len(services), 1)
+	is.Equal(services[0].Service, "b")
+}
+
+func TestPinRemote(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	is.Nil(s.PinRemoteServiceAdd(ctx, "srv", "https://pins.example.com", "secret"))
+
+	one, err := s.Add(strings.NewReader("one"))
+	is.Nil(err)
+	_, err = s.PinRemoteAdd(ctx, "unknown", one)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "service not known"))
+
+	two, err := s.Add(strings.NewReader("two"))
+	is.Nil(err)
+
+	pin, err := s.PinRemoteAdd(ctx, "srv", one, PinRemote.Name("one"))
+	is.Nil(err)
+	is.Equal(*pin, RemotePin{Status: RemotePinPinned, Cid: one, Name: "one"})
+	pin, err = s.PinRemoteAdd(ctx, "srv", "/ipfs/"+two, PinRemote.Name("two"), PinRemote.Background(true))
+	is.Nil(err)
+	is.Equal(pin.Status, RemotePinQueued)
+
+	ls := func(opts ...PinRemoteOpt) []RemotePin {
+		ch, err := s.PinRemoteLs(ctx, "srv", opts...)
+		is.Nil(err)
+		var pins []RemotePin
+		for p := range ch {
+			is.Nil(p.Err)
+			pins = append(pins, p)
+		}
+		return pins
+	}
+
+	// only pinned pins by default
+	pins := ls()
+	is.Equal(len(pins), 1)
+	is.Equal(pins[0].Cid, one)
+
+	pins = ls(PinRemote.Status(RemotePinQueued, RemotePinPinned))
+	is.Equal(len(pins), 2)
+	pins = ls(PinRemote.Status(RemotePinQueued, RemotePinPinned), PinRemote.Cid(two))
+	is.Equal(len(pins), 1)
+	is.Equal(pins[0].Name, "two")
+	pins = ls(PinRemote.Status(RemotePinQueued, RemotePinPinned), PinRemote.Name("one"))
+	is.Equal(len(pins), 1)
+	is.Equal(pins[0].Cid, one)
+
+	_, err = s.PinRemoteLs(ctx, "srv", PinRemote.Status("done"))
+	is.Err(err)
+
+	all := PinRemote.Status(RemotePinQueued, RemotePinPinning, RemotePinPinned, RemotePinFailed)
+	err = s.PinRemoteRm(ctx, "srv", all)
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "--force"))
+	is.Equal(len(ls(all)), 2)
+
+	is.Nil(s.PinRemoteRm(ctx, "srv", PinRemote.Cid(one)))
+	is.Equal(len(ls(all)), 1)
+	is.Nil(s.PinRemoteRm(ctx, "srv", all, PinRemote.Force(true)))
+	is.Equal(len(ls(all)), 0)
+}
@@ -1,9 +1,13 @@
 package shell
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"time"
 
+	"github.com/ipfs/go-ipfs-api/options"
 	"github.com/libp2p/go-libp2p/core/peer"
 	mbase "github.com/multiformats/go-multibase"
 )
@@ -17,20 +21,118 @@ type Message struct {
 }
 
 // PubSubSubscription allow you to receive pubsub records that where published on the network.
+//
+// Messages are delivered on the channel returned by Messages, or one by one
+// by Next. The channel is closed when the subscription ends: when it is
+// cancelled, when its context is done, or when the connection to the daemon
+// is lost and resubscribing isn't enabled.
 type PubSubSubscription struct {
-	resp io.Closer
-	dec  *json.Decoder
+	messages chan *Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
 }
 
-func newPubSubSubscription(resp io.ReadCloser) *PubSubSubscription {
-	return &PubSubSubscription{
-		resp: resp,
-		dec:  json.NewDecoder(resp),
+// subscribeFunc subscribes to the topic, returning the stream of messages.
+type subscribeFunc func(ctx context.Context) (io.ReadCloser, error)
+
+func newPubSubSubscription(ctx context.Context, subscribe subscribeFunc, settings *options.PubSubSubscribeSettings) (*PubSubSubscription, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+
+	// connect
+	resp, err := subscribe(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &PubSubSubscription{
+		messages: make(chan *Message),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		defer close(s.messages)
+
+		backoff := &RetryPolicy{
+			MinBackoff: settings.MinBackoff,
+			MaxBackoff: settings.MaxBackoff,
+			Jitter:     0.2,
+		}
+		for {
+			err := s.receive(ctx, resp)
+			resp.Close()
+			switch {
+			case ctx.Err() != nil:
+				s.err = parent.Err()
+				return
+			case !settings.Resubscribe || !resubscribable(err):
+				if err != io.EOF {
+					s.err = err
+				}
+				return
+			}
+
+			since := time.Now()
+			for retry := 1; ; retry++ {
+				if sleepCtx(ctx, backoff.backoff(retry)) != nil {
+					s.err = parent.Err()
+					return
+				}
+				var serr error
+				if resp, serr = subscribe(ctx); serr == nil {
+					break
+				}
+				if ctx.Err() != nil {
+					s.err = parent.Err()
+					return
+				}
+				if !resubscribable(serr) {
+					s.err = serr
+					return
+				}
+			}
+			if settings.OnGap != nil {
+				settings.OnGap(since, time.Now(), err)
+			}
+		}
+	}()
+	return s, nil
+}
+
+// resubscribable tells whether subscribing again may get past err, which
+// interrupted the subscription or failed to restore it. Lost connections and
+// overloaded daemons are worth retrying, while an undecodable message or an
+// error of the daemon, like pubsub being disabled, would only happen again.
+func resubscribable(err error) bool {
+	status := 0
+	var e *Error
+	if errors.As(err, &e) {
+		status = e.StatusCode
 	}
+	return IsRetryable(status, err)
 }
 
-// Next waits for the next record and returns that.
-func (s *PubSubSubscription) Next() (*Message, error) {
+// receive delivers the messages of the stream until it ends.
+func (s *PubSubSubscription) receive(ctx context.Context, resp io.Reader) error {
+	dec := json.NewDecoder(resp)
+	for {
+		msg, err := decodeMessage(dec)
+		if err != nil {
+			return err
+		}
+		select {
+		case s.messages <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func decodeMessage(dec *json.Decoder) (*Message, error) {
 	var r struct {
 		From     string   `json:"from,omitempty"`
 		Data     string   `json:"data,omitempty"`
@@ -38,7 +140,7 @@ func (s *PubSubSubscription) Next() (*Message, error) {
 		TopicIDs []string `json:"topicIDs,omitempty"`
 	}
 
-	err := s.dec.Decode(&r)
+	err := dec.Decode(&r)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +175,40 @@ func (s *PubSubSubscription) Next() (*Message, error) {
 	}, nil
 }
 
+// Messages returns the channel the messages are delivered on. It is closed
+// when the subscription ends, after which Err tells why.
+func (s *PubSubSubscription) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Next waits for the next record and returns that. It returns io.EOF once the
+// subscription has ended without error.
+func (s *PubSubSubscription) Next() (*Message, error) {
+	msg, ok := <-s.messages
+	if !ok {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+// Err returns the error that ended the subscription: the error of its
+// context, or the error of the connection to the daemon. It is nil while the
+// subscription is active, and after it is cancelled.
+func (s *PubSubSubscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
 // Cancel cancels the given subscription.
 func (s *PubSubSubscription) Cancel() error {
-	return s.resp.Close()
+	s.cancel()
+	<-s.done
+	return nil
 }
@@ -0,0 +1,254 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPubSubSubscribeChannel(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	topic := "channel-" + randString(8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := s.PubSubSubscribeCtx(ctx, topic)
+	is.Nil(err)
+
+	is.Nil(s.PubSubPublish(topic, "one"))
+	select {
+	case msg := <-sub.Messages():
+		is.Equal(msg.Data, "one")
+		is.Equal(msg.TopicIDs, []string{topic})
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-sub.Messages():
+		is.False(ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed on cancel")
+	}
+	is.Equal(sub.Err(), context.Canceled)
+	_, err = sub.Next()
+	is.Equal(err, context.Canceled)
+
+	sub, err = s.PubSubSubscribe(topic)
+	is.Nil(err)
+	is.Nil(sub.Cancel())
+	is.Nil(sub.Err())
+	_, err = sub.Next()
+	is.Equal(err, io.EOF)
+}
+
+func TestPubSubResubscribe(t *testing.T) {
+	is := is.New(t)
+
+	fake := shelltest.NewUnstartedServer()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	s := NewShell(srv.URL)
+	ctx := context.Background()
+
+	// without resubscribing, the subscription ends with the connection
+	sub, err := s.PubSubSubscribeCtx(ctx, "topic")
+	is.Nil(err)
+	srv.CloseClientConnections()
+	_, ok := <-sub.Messages()
+	is.False(ok)
+	is.NotNil(sub.Err())
+
+	gaps := make(chan error, 1)
+	sub, err = s.PubSubSubscribeCtx(ctx, "topic",
+		options.PubSub.Backoff(time.Millisecond, 10*time.Millisecond),
+		options.PubSub.OnGap(func(since, until time.Time, cause error) {
+			is.False(until.Before(since))
+			gaps <- cause
+		}),
+	)
+	is.Nil(err)
+	defer sub.Cancel()
+
+	srv.CloseClientConnections()
+	select {
+	case cause := <-gaps:
+		is.NotNil(cause)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not resubscribed")
+	}
+
+	is.Nil(s.PubSubPublish("topic", "after the gap"))
+	msg, err := sub.Next()
+	is.Nil(err)
+	is.Equal(string(msg.Data), "after the gap")
+
+	_, err = s.PubSubSubscribeCtx(ctx, "topic", options.PubSub.Backoff(0, time.Second))
+	is.NotNil(err)
+}
+
+func TestPubSubResubscribePermanentError(t *testing.T) {
+	is := is.New(t)
+
+	fake := shelltest.NewUnstartedServer()
+	var subs, garbage int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v0/pubsub/sub" {
+			switch {
+			case atomic.LoadInt32(&garbage) == 1:
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"from": 1}` + "\n"))
+				return
+			case atomic.AddInt32(&subs, 1) > 1:
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"Message": "experimental pubsub feature not enabled", "Code": 0, "Type": "error"}`))
+				return
+			}
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	s := NewShell(srv.URL)
+	ctx := context.Background()
+
+	gaps := 0
+	resubscribe := []options.PubSubSubscribeOption{
+		options.PubSub.Backoff(time.Millisecond, 10*time.Millisecond),
+		options.PubSub.OnGap(func(since, until time.Time, cause error) { gaps++ }),
+	}
+
+	// the daemon comes back with pubsub disabled
+	sub, err := s.PubSubSubscribeCtx(ctx, "topic", resubscribe...)
+	is.Nil(err)
+	srv.CloseClientConnections()
+	_, err = sub.Next()
+	is.Err(err)
+	is.True(strings.Contains(err.Error(), "not enabled"))
+	is.Equal(atomic.LoadInt32(&subs), int32(2))
+
+	// a message that can't be decoded would be sent again
+	atomic.StoreInt32(&garbage, 1)
+	sub, err = s.PubSubSubscribeCtx(ctx, "topic", resubscribe...)
+	is.Nil(err)
+	_, err = sub.Next()
+	is.Err(err)
+	is.NotEqual(err, io.EOF)
+	is.Equal(gaps, 0)
+}
+
+func TestPubSubLsAndPeers(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+	topic := "ls\n topic " + randString(8)
+
+	topics, err := s.PubSubLs(ctx)
+	is.Nil(err)
+	for _, tp := range topics {
+		is.NotEqual(tp, topic)
+	}
+
+	sub, err := s.PubSubSubscribeCtx(ctx, topic)
+	is.Nil(err)
+	topics, err = s.PubSubLs(ctx)
+	is.Nil(err)
+	found := false
+	for _, tp := range topics {
+		found = found || tp == topic
+	}
+	is.True(found)
+
+	peers, err := s.PubSubPeers(ctx, topic)
+	is.Nil(err)
+	is.Equal(len(peers), 0)
+	_, err = s.PubSubPeers(ctx, "")
+	is.Nil(err)
+
+	is.Nil(sub.Cancel())
+}
+
+func TestPubSubPublishBinary(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+	topic := "binary-" + randString(8)
+
+	sub, err := s.PubSubSubscribeCtx(ctx, topic)
+	is.Nil(err)
+	defer sub.Cancel()
+
+	payload := []byte{0, 0xff, '\n', 0xc3, 0x28, 0}
+	is.Nil(s.PubSubPublishBytes(ctx, topic, payload))
+	msg, err := sub.Next()
+	is.Nil(err)
+	is.Equal(msg.Data, payload)
+
+	is.Nil(s.PubSubPublishReader(ctx, topic, bytes.NewReader(payload[1:])))
+	msg, err = sub.Next()
+	is.Nil(err)
+	is.Equal(msg.Data, payload[1:])
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	is.Err(s.PubSubPublishBytes(cctx, topic, payload))
+}
+
+type testEvent struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestPubSubPublisher(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+	topic := "typed-" + randString(8)
+
+	sub, err := s.PubSubSubscribeCtx(ctx, topic)
+	is.Nil(err)
+	defer sub.Cancel()
+
+	event := testEvent{Name: "added", Count: 3, Tags: []string{"a", "b"}}
+	for _, enc := range []MessageEncoder{JSONEncoder, CBOREncoder} {
+		is.Nil(NewPubSubPublisher[testEvent](s, topic, enc).Publish(ctx, event))
+		msg, err := sub.Next()
+		is.Nil(err)
+		var decoded testEvent
+		is.Nil(msg.Decode(enc, &decoded))
+		is.Equal(decoded, event)
+	}
+
+	is.Nil(NewPubSubPublisher[*testEvent](s, topic, CBOREncoder).Publish(ctx, &event))
+	msg, err := sub.Next()
+	is.Nil(err)
+	var decoded testEvent
+	is.Nil(msg.Decode(CBOREncoder, &decoded))
+	is.Equal(decoded, event)
+	is.Err(msg.Decode(CBOREncoder, decoded))
+
+	pb := NewPubSubPublisher[*wrapperspb.StringValue](s, topic, ProtobufEncoder)
+	is.Nil(pb.Publish(ctx, wrapperspb.String("proto")))
+	msg, err = sub.Next()
+	is.Nil(err)
+	var value wrapperspb.StringValue
+	is.Nil(msg.Decode(ProtobufEncoder, &value))
+	is.Equal(value.GetValue(), "proto")
+
+	is.Err(NewPubSubPublisher[testEvent](s, topic, ProtobufEncoder).Publish(ctx, event))
+	is.Err(NewPubSubPublisher[map[int]string](s, topic, CBOREncoder).Publish(ctx, map[int]string{1: "a"}))
+	is.Err(NewPubSubPublisher[chan int](s, topic, CBOREncoder).Publish(ctx, make(chan int)))
+}
@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"google.golang.org/protobuf/proto"
+)
+
+// MessageEncoder converts Go values to pubsub message payloads and back.
+type MessageEncoder interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+var (
+	// JSONEncoder encodes values with encoding/json.
+	JSONEncoder MessageEncoder = jsonEncoder{}
+	// CBOREncoder encodes values as DAG-CBOR. Values are mapped like with
+	// encoding/json, json struct tags included; []byte values are encoded
+	// as bytes and cid.Cid values as links.
+	CBOREncoder MessageEncoder = cborEncoder{}
+	// ProtobufEncoder encodes values implementing proto.Message.
+	ProtobufEncoder MessageEncoder = protobufEncoder{}
+)
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonEncoder) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type cborEncoder struct{}
+
+func (cborEncoder) Encode(v interface{}) ([]byte, error) {
+	return encodeValue(v, dagcbor.Encode)
+}
+
+func (cborEncoder) Decode(data []byte, v interface{}) error {
+	return decodeValue(data, dagcbor.Decode, v)
+}
+
+type protobufEncoder struct{}
+
+func (protobufEncoder) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufEncoder) Decode(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// Decode decodes the data of the message into v, which was published with
+// the given encoder.
+func (m *Message) Decode(enc MessageEncoder, v interface{}) error {
+	return enc.Decode(m.Data, v)
+}
+
+// PubSubPublisher publishes values of type T to a topic.
+type PubSubPublisher[T any] struct {
+	shell *Shell
+	topic string
+	enc   MessageEncoder
+}
+
+// NewPubSubPublisher returns a publisher of values of type T to the topic,
+// encoded with enc. Subscribers decode them with Message.Decode.
+func NewPubSubPublisher[T any](s *Shell, topic string, enc MessageEncoder) *PubSubPublisher[T] {
+	return &PubSubPublisher[T]{shell: s, topic: topic, enc: enc}
+}
+
+// Publish encodes v and publishes it.
+func (p *PubSubPublisher[T]) Publish(ctx context.Context, v T) error {
+	data, err := p.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	return p.shell.PubSubPublishBytes(ctx, p.topic, data)
+}
@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-api/options"
+)
+
+// RepoGCResult is a block removed by RepoGC, or an error.
+type RepoGCResult struct {
+	Key string
+	// Err is set, and Key is empty, for a block that couldn't be removed
+	// and on the last result of a failed GC.
+	Err error
+}
+
+// RepoGC removes the blocks that aren't pinned nor part of MFS, sending the
+// keys of the removed blocks as they go. The channel is closed once the GC is
+// done, or after a result carrying the error it failed with.
+func (s *Shell) RepoGC(ctx context.Context, opts ...options.RepoGCOption) (<-chan RepoGCResult, error) {
+	cfg, err := options.RepoGCOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Request("repo/gc").
+		Option("stream-errors", cfg.StreamErrors).
+		Option("silent", cfg.Silent).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan RepoGCResult)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var raw struct {
+				Key   cid.Cid
+				Error string
+			}
+			var res RepoGCResult
+			err := dec.Decode(&raw)
+			switch {
+			case err == io.EOF:
+				return
+			case err != nil:
+				res.Err = err
+			case raw.Error != "":
+				res.Err = errors.New(raw.Error)
+			default:
+				res.Key = raw.Key.String()
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// RepoStats are the statistics of the repository.
+type RepoStats struct {
+	// RepoSize is the size of the repository in bytes.
+	RepoSize uint64
+	// StorageMax is the maximum size of the datastore in bytes.
+	StorageMax uint64
+	// NumObjects, RepoPath and Version aren't set for size only stats.
+	NumObjects uint64
+	RepoPath   string
+	Version    string
+}
+
+// RepoStat returns the statistics of the repository. If sizeOnly is true,
+// only RepoSize and StorageMax are reported, which is faster as the blocks
+// aren't counted.
+func (s *Shell) RepoStat(ctx context.Context, sizeOnly bool) (*RepoStats, error) {
+	var out RepoStats
+	if err := s.Request("repo/stat").
+		Option("size-only", sizeOnly).
+		Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RepoVerifyProgress is a progress report of RepoVerify.
+type RepoVerifyProgress struct {
+	// Progress is the number of blocks checked so far.
+	Progress int
+	// Msg reports a corrupt block, or the completion of the check.
+	Msg string
+	// Err is set on the last report if the check failed, which it does if
+	// any block is corrupt.
+	Err error `json:"-"`
+}
+
+// RepoVerify checks the integrity of all the blocks of the repository,
+// sending progress reports as they go. The channel is closed once the check
+// is done.
+func (s *Shell) RepoVerify(ctx context.Context) (<-chan RepoVerifyProgress, error) {
+	resp, err := s.Request("repo/verify").Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+
+	out := make(chan RepoVerifyProgress)
+	go func() {
+		defer close(out)
+		defer resp.Close()
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var p RepoVerifyProgress
+			err := dec.Decode(&p)
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				p = RepoVerifyProgress{Err: err}
+			}
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// RepoVersion returns the version of the repository format, like "14".
+func (s *Shell) RepoVersion(ctx context.Context) (string, error) {
+	var out struct{ Version string }
+	if err := s.Request("repo/version").Exec(ctx, &out); err != nil {
+		return "", err
+	}
+	return out.Version, nil
+}
@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cheekybits/is"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+)
+
+func TestRepoGC(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	pinned, err := s.Add(strings.NewReader("pinned"))
+	is.Nil(err)
+	garbage, err := s.Add(strings.NewReader("garbage"), Pin(false))
+	is.Nil(err)
+	is.Nil(s.FilesWrite(ctx, "/kept", strings.NewReader("kept"), FilesWrite.Create(true)))
+
+	before, err := s.RepoStat(ctx, false)
+	is.Nil(err)
+	is.Equal(before.StorageMax, uint64(10_000_000_000))
+	is.True(before.NumObjects >= 3)
+	is.Equal(before.Version, "fs-repo@14")
+
+	results, err := s.RepoGC(ctx)
+	is.Nil(err)
+	// blocks are reported by their raw CID
+	removed := make(map[string]bool)
+	for res := range results {
+		is.Nil(res.Err)
+		removed[res.Key] = true
+	}
+	c, err := cid.Decode(garbage)
+	is.Nil(err)
+	is.True(removed[cid.NewCidV1(cid.Raw, c.Hash()).String()])
+
+	after, err := s.RepoStat(ctx, true)
+	is.Nil(err)
+	is.True(after.RepoSize < before.RepoSize)
+	is.Equal(after.NumObjects, uint64(0))
+
+	_, err = s.Cat(pinned)
+	is.Nil(err)
+	_, err = s.Cat(garbage)
+	is.Err(err)
+	r, err := s.FilesRead(ctx, "/kept")
+	is.Nil(err)
+	r.Close()
+
+	results, err = s.RepoGC(ctx, options.Repo.StreamErrors(true))
+	is.Nil(err)
+	for range results {
+		t.Fatal("nothing left to remove")
+	}
+}
+
+func TestRepoVerify(t *testing.T) {
+	is := is.New(t)
+	fake := shelltest.NewServer()
+	defer fake.Close()
+	s := NewShell(fake.URL())
+	ctx := context.Background()
+
+	for _, data := range []string{"one", "two", "three"} {
+		_, err := s.Add(strings.NewReader(data))
+		is.Nil(err)
+	}
+
+	reports, err := s.RepoVerify(ctx)
+	is.Nil(err)
+	var last RepoVerifyProgress
+	progress := 0
+	for p := range reports {
+		is.Nil(p.Err)
+		if p.Msg == "" {
+			is.Equal(p.Progress, progress+1)
+			progress = p.Progress
+		}
+		last = p
+	}
+	is.Equal(progress, 3)
+	is.Equal(last.Msg, "verify complete, all blocks validated.")
+
+	version, err := s.RepoVersion(ctx)
+	is.Nil(err)
+	is.Equal(version, "14")
+}
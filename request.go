@@ -3,12 +3,10 @@ package shell
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 
 	files "github.com/ipfs/boxo/files"
@@ -20,8 +18,13 @@ type Request struct {
 	Command string
 	Args    []string
 	Opts    map[string]string
-	Body    io.Reader
-	Headers map[string]string
+	// MultiOpts are options given once per value, like string array
+	// options.
+	MultiOpts map[string][]string
+	Body      io.Reader
+	Headers   map[string]string
+	// Logger receives diagnostics about malformed responses. Optional.
+	Logger Logger
 }
 
 func NewRequest(ctx context.Context, url, command string, args ...string) *Request {
@@ -51,7 +54,7 @@ func (r *trailerReader) Read(b []byte) (int, error) {
 	n, err := r.resp.Body.Read(b)
 	if err != nil {
 		if e := r.resp.Trailer.Get("X-Stream-Error"); e != "" {
-			err = errors.New(e)
+			err = &Error{Message: e, StatusCode: r.resp.StatusCode}
 		}
 	}
 	return n, err
@@ -90,10 +93,15 @@ func (r *Response) Decode(dec interface{}) error {
 	return json.NewDecoder(r.Output).Decode(dec)
 }
 
+// Error is an error reported by the daemon. Use errors.Is to match it against
+// the sentinel errors of this package.
 type Error struct {
 	Command string
 	Message string
 	Code    int
+	// StatusCode is the HTTP status of the response, or 200 for errors
+	// reported after the output started streaming.
+	StatusCode int `json:"-"`
 }
 
 func (e *Error) Error() string {
@@ -126,9 +134,22 @@ func (r *Request) Send(c *http.Client) (*Response, error) {
 		req.Header.Set("Content-Disposition", "form-data; name=\"files\"")
 	}
 
+	if r.Body != nil {
+		// The daemon may respond while still reading the body, e.g. with add
+		// progress. On a kept-alive connection Go's HTTP/1.1 server discards
+		// the rest of the body when the response starts, so uploads get a
+		// connection of their own.
+		req.Close = true
+	}
+
+	logger := r.Logger
+	if logger == nil {
+		logger = nopLogger{}
+	}
+
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, wrapTransportError(err)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
@@ -140,7 +161,8 @@ func (r *Request) Send(c *http.Client) (*Response, error) {
 	nresp.Output = &trailerReader{resp}
 	if resp.StatusCode >= http.StatusBadRequest {
 		e := &Error{
-			Command: r.Command,
+			Command:    r.Command,
+			StatusCode: resp.StatusCode,
 		}
 		switch {
 		case resp.StatusCode == http.StatusNotFound:
@@ -148,21 +170,24 @@ func (r *Request) Send(c *http.Client) (*Response, error) {
 		case contentType == "text/plain":
 			out, err := io.ReadAll(resp.Body)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ipfs-shell: warning! response (%d) read error: %s\n", resp.StatusCode, err)
+				logger.Printf("ipfs-shell: warning! response (%d) read error: %s", resp.StatusCode, err)
 			}
 			e.Message = string(out)
 		case contentType == "application/json":
 			if err = json.NewDecoder(resp.Body).Decode(e); err != nil {
-				fmt.Fprintf(os.Stderr, "ipfs-shell: warning! response (%d) unmarshall error: %s\n", resp.StatusCode, err)
+				logger.Printf("ipfs-shell: warning! response (%d) unmarshall error: %s", resp.StatusCode, err)
 			}
 		default:
-			fmt.Fprintf(os.Stderr, "ipfs-shell: warning! unhandled response (%d) encoding: %s", resp.StatusCode, contentType)
+			logger.Printf("ipfs-shell: warning! unhandled response (%d) encoding: %s", resp.StatusCode, contentType)
 			out, err := io.ReadAll(resp.Body)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ipfs-shell: response (%d) read error: %s\n", resp.StatusCode, err)
+				logger.Printf("ipfs-shell: response (%d) read error: %s", resp.StatusCode, err)
 			}
 			e.Message = fmt.Sprintf("unknown ipfs-shell error encoding: %q - %q", contentType, out)
 		}
+		if e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
 		nresp.Error = e
 		nresp.Output = nil
 
@@ -183,6 +208,11 @@ func (r *Request) getURL() string {
 	for k, v := range r.Opts {
 		values.Add(k, v)
 	}
+	for k, vs := range r.MultiOpts {
+		for _, v := range vs {
+			values.Add(k, v)
+		}
+	}
 
 	return fmt.Sprintf("%s/%s?%s", r.ApiBase, r.Command, values.Encode())
 }
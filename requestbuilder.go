@@ -14,6 +14,7 @@ type RequestBuilder struct {
 	command string
 	args    []string
 	opts    map[string]string
+	multi   map[string][]string
 	headers map[string]string
 	body    io.Reader
 
@@ -42,10 +43,18 @@ func (r *RequestBuilder) Body(body io.Reader) *RequestBuilder {
 	return r
 }
 
-// Option sets the given option.
+// Option sets the given option. A []string value sets the option once per
+// element, as expected by string array options.
 func (r *RequestBuilder) Option(key string, value interface{}) *RequestBuilder {
 	var s string
 	switch v := value.(type) {
+	case []string:
+		if r.multi == nil {
+			r.multi = make(map[string][]string, 1)
+		}
+		r.multi[key] = v
+		delete(r.opts, key)
+		return r
 	case bool:
 		s = strconv.FormatBool(v)
 	case string:
@@ -60,6 +69,7 @@ func (r *RequestBuilder) Option(key string, value interface{}) *RequestBuilder {
 		r.opts = make(map[string]string, 1)
 	}
 	r.opts[key] = s
+	delete(r.multi, key)
 	return r
 }
 
@@ -72,12 +82,71 @@ func (r *RequestBuilder) Header(name, value string) *RequestBuilder {
 	return r
 }
 
-// Send sends the request and return the response.
+// Send sends the request and return the response. Failed attempts are
+// retried according to the shell's RetryPolicy, if any.
 func (r *RequestBuilder) Send(ctx context.Context) (*Response, error) {
+	policy := r.shell.retry
+	if policy == nil || policy.MaxAttempts < 2 {
+		return r.send(ctx)
+	}
+
+	// Remember where the body starts so it can be rewound for a replay.
+	var start int64
+	seeker, replayable := r.body.(io.Seeker)
+	if r.body == nil {
+		replayable = true
+	} else if replayable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seeker, replayable = nil, false
+		}
+	}
+	replayable = replayable && policy.idempotent(r.command)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := r.send(ctx)
+
+		status, failure := 0, err
+		switch {
+		case err != nil:
+		case resp.Error != nil:
+			status, failure = resp.Error.StatusCode, resp.Error
+		default:
+			return resp, nil
+		}
+
+		if attempt >= policy.MaxAttempts ||
+			!(isDialError(err) || replayable) ||
+			!policy.retryable(status, failure) {
+			return resp, err
+		}
+		if err := sleepCtx(ctx, policy.backoff(attempt)); err != nil {
+			return nil, err
+		}
+		if seeker != nil {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *RequestBuilder) send(ctx context.Context) (*Response, error) {
 	req := NewRequest(ctx, r.shell.url, r.command, r.args...)
 	req.Opts = r.opts
+	req.MultiOpts = r.multi
 	req.Headers = r.headers
 	req.Body = r.body
+	req.Logger = r.shell.logger
+	if auth := r.shell.auth; auth != "" {
+		if _, ok := r.headers["Authorization"]; !ok {
+			req.Headers = make(map[string]string, len(r.headers)+1)
+			for k, v := range r.headers {
+				req.Headers[k] = v
+			}
+			req.Headers["Authorization"] = auth
+		}
+	}
 	return req.Send(&r.shell.httpcli)
 }
 
@@ -18,6 +18,7 @@ func TestRequestBuilder(t *testing.T) {
 	r.Option("bytekey", []byte("bytevalue"))
 	r.Option("boolkey", true)
 	r.Option("otherkey", now)
+	r.Option("listkey", []string{"a", "b"})
 	r.Header("some-header", "header-value")
 	r.Header("some-header-2", "header-value-2")
 
@@ -28,6 +29,9 @@ func TestRequestBuilder(t *testing.T) {
 		"boolkey":   "true",
 		"otherkey":  now.String(),
 	})
+	is.Equal(r.multi, map[string][]string{
+		"listkey": {"a", "b"},
+	})
 	is.Equal(r.headers, map[string]string{
 		"some-header":   "header-value",
 		"some-header-2": "header-value-2",
@@ -0,0 +1,195 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy configures how requests are retried when they fail with a
+// transient error, such as the daemon refusing connections while it restarts.
+//
+// Requests that never reached the daemon (the connection could not be
+// established) are always safe to retry. Requests that may have reached it
+// are only replayed when the command is idempotent and the request body can
+// be rewound. The zero value of every field selects a sensible default.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. It doubles with every
+	// subsequent retry, up to MaxBackoff. Default 100ms and 5s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter randomizes each delay by up to the given fraction in either
+	// direction, e.g. 0.2 yields delays between 80% and 120% of the nominal
+	// value. It is clamped to [0, 1].
+	Jitter float64
+
+	// Retryable reports whether an attempt that failed with the given HTTP
+	// status code (zero when no response was received) and error may be
+	// retried. Defaults to IsRetryable.
+	Retryable func(status int, err error) bool
+
+	// Idempotent reports whether the given command can be replayed after it
+	// possibly reached the daemon. Defaults to IsIdempotent.
+	Idempotent func(command string) bool
+}
+
+// IsRetryable is the default classification of failed attempts: network
+// errors, and the statuses proxies and load balancers answer with while the
+// daemon is unavailable. Kubo reports ordinary command failures with a 500,
+// so those are not retried.
+func IsRetryable(status int, err error) bool {
+	switch status {
+	case 0:
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// *url.Error implements net.Error itself, look at what it wraps.
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// idempotentCommands either don't modify the node, or are keyed by content so
+// running them twice has the same effect as running them once.
+var idempotentCommands = map[string]bool{
+	"add":              true,
+	"bitswap/stat":     true,
+	"block/get":        true,
+	"block/put":        true,
+	"block/stat":       true,
+	"bootstrap/list":   true,
+	"cat":              true,
+	"config/show":      true,
+	"dag/export":       true,
+	"dag/get":          true,
+	"dag/import":       true,
+	"dag/put":          true,
+	"dag/resolve":      true,
+	"dag/stat":         true,
+	"dht/findpeer":     true,
+	"dht/findprovs":    true,
+	"file/ls":          true,
+	"files/ls":         true,
+	"files/read":       true,
+	"files/stat":       true,
+	"get":              true,
+	"id":               true,
+	"key/list":         true,
+	"ls":               true,
+	"name/resolve":     true,
+	"object/get":       true,
+	"object/stat":      true,
+	"pin/add":          true,
+	"pin/ls":           true,
+	"pubsub/ls":        true,
+	"pubsub/peers":     true,
+	"refs":             true,
+	"refs/local":       true,
+	"repo/stat":        true,
+	"repo/version":     true,
+	"resolve":          true,
+	"stats/bw":         true,
+	"swarm/peering/ls": true,
+	"swarm/peers":      true,
+	"version":          true,
+}
+
+// IsIdempotent is the default idempotency classification of commands.
+func IsIdempotent(command string) bool {
+	return idempotentCommands[command]
+}
+
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy,
+// the default, makes a single attempt per request.
+func (s *Shell) SetRetryPolicy(p *RetryPolicy) {
+	s.retry = p
+}
+
+func (p *RetryPolicy) retryable(status int, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(status, err)
+	}
+	return IsRetryable(status, err)
+}
+
+func (p *RetryPolicy) idempotent(command string) bool {
+	if p.Idempotent != nil {
+		return p.Idempotent(command)
+	}
+	return IsIdempotent(command)
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if max < min {
+		max = min
+	}
+
+	d := float64(min) * math.Pow(2, float64(retry-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	jitter := math.Max(0, math.Min(1, p.Jitter))
+	d += d * jitter * (2*rand.Float64() - 1)
+	return time.Duration(d)
+}
+
+// isDialError reports whether err happened while establishing the
+// connection, which means nothing was sent to the daemon.
+func isDialError(err error) bool {
+	var oerr *net.OpError
+	return errors.As(err, &oerr) && oerr.Op == "dial"
+}
+
+// sleepCtx waits for d, returning early with the context error if ctx is done
+// first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
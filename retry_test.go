@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cheekybits/is"
+)
+
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(status)
+			fmt.Fprint(w, "unavailable")
+			return
+		}
+		fmt.Fprintf(w, `{"Version":"0.22.0","Commit":%q}`, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+var testRetryPolicy = &RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  10 * time.Millisecond,
+	Jitter:      0.5,
+}
+
+func TestRetryStatus(t *testing.T) {
+	is := is.New(t)
+
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+	s := NewShell(srv.URL)
+	s.SetRetryPolicy(testRetryPolicy)
+
+	version, _, err := s.Version()
+	is.Nil(err)
+	is.Equal(version, "0.22.0")
+	is.Equal(atomic.LoadInt32(calls), 3)
+
+	// the body is rewound before each attempt
+	atomic.StoreInt32(calls, 0)
+	var out struct{ Commit string }
+	err = s.Request("block/put").BodyString("block data").Exec(context.Background(), &out)
+	is.Nil(err)
+	is.Equal(out.Commit, "block data")
+	is.Equal(atomic.LoadInt32(calls), 3)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	is := is.New(t)
+
+	srv, calls := flakyServer(t, 10, http.StatusBadGateway)
+	s := NewShell(srv.URL)
+	s.SetRetryPolicy(testRetryPolicy)
+
+	_, _, err := s.Version()
+	is.Err(err)
+	is.Equal(atomic.LoadInt32(calls), 4)
+}
+
+func TestRetrySkipped(t *testing.T) {
+	is := is.New(t)
+
+	// Kubo reports command failures with a 500
+	srv, calls := flakyServer(t, 1, http.StatusInternalServerError)
+	s := NewShell(srv.URL)
+	s.SetRetryPolicy(testRetryPolicy)
+
+	_, _, err := s.Version()
+	is.Err(err)
+	is.Equal(atomic.LoadInt32(calls), 1)
+
+	// commands with side effects are not replayed once they reached the daemon
+	srv, calls = flakyServer(t, 1, http.StatusServiceUnavailable)
+	s = NewShell(srv.URL)
+	s.SetRetryPolicy(testRetryPolicy)
+
+	err = s.Request("files/rm", "/foo").Exec(context.Background(), nil)
+	is.Err(err)
+	is.Equal(atomic.LoadInt32(calls), 1)
+
+	// and without a policy there is a single attempt
+	srv, calls = flakyServer(t, 1, http.StatusServiceUnavailable)
+	s = NewShell(srv.URL)
+
+	_, _, err = s.Version()
+	is.Err(err)
+	is.Equal(atomic.LoadInt32(calls), 1)
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	is := is.New(t)
+
+	// grab a free port, and only start listening on it a bit later
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	is.Nil(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	srv, calls := flakyServer(t, 0, 0)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		http.Serve(l, srv.Config.Handler)
+	}()
+
+	s := NewShell(addr)
+	s.SetRetryPolicy(&RetryPolicy{MaxAttempts: 10, MinBackoff: 20 * time.Millisecond})
+
+	// not idempotent, but it never reached the daemon
+	err = s.Request("files/rm", "/foo").Exec(context.Background(), nil)
+	is.Nil(err)
+	is.Equal(atomic.LoadInt32(calls), 1)
+}
+
+func TestRetryContext(t *testing.T) {
+	is := is.New(t)
+
+	srv, _ := flakyServer(t, 10, http.StatusServiceUnavailable)
+	s := NewShell(srv.URL)
+	s.SetRetryPolicy(&RetryPolicy{MaxAttempts: 10, MinBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := s.VersionCtx(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	is := is.New(t)
+
+	p := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	is.Equal(p.backoff(1), time.Second)
+	is.Equal(p.backoff(2), 2*time.Second)
+	is.Equal(p.backoff(3), 4*time.Second)
+	is.Equal(p.backoff(4), 5*time.Second)
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		is.True(d >= time.Second && d <= 3*time.Second)
+	}
+}
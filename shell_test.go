@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sort"
@@ -21,6 +23,7 @@ import (
 	files "github.com/ipfs/boxo/files"
 
 	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
 )
 
 const (
@@ -136,6 +139,272 @@ func TestAddDirWithCidV1(t *testing.T) {
 	is.Equal(cid, "bafybeibgegl5yqme2jehvvneapbq7he5ahi3tmk4cpmlagrggeji6hzayq")
 }
 
+func TestAddStream(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+
+	data := bytes.Repeat([]byte(randString(32)), 1<<15) // 1MiB
+	events, err := s.AddStream(context.Background(), bytes.NewReader(data), CidVersion(1))
+	is.Nil(err)
+
+	var progress []int64
+	var root AddEvent
+	for ev := range events {
+		is.Nil(ev.Err)
+		switch ev.Type {
+		case AddProgress:
+			progress = append(progress, ev.Bytes)
+		case AddRoot:
+			root = ev
+		default:
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	}
+	is.True(len(progress) > 1)
+	is.Equal(progress[len(progress)-1], len(data))
+	is.Equal(root.Name, root.Hash)
+	is.True(strings.HasPrefix(root.Hash, "bafy"))
+	is.True(root.Size > uint64(len(data)))
+
+	events, err = s.AddStream(context.Background(), bytes.NewReader(data), Progress(false))
+	is.Nil(err)
+	ev := <-events
+	is.Equal(ev.Type, AddRoot)
+	_, ok := <-events
+	is.False(ok)
+
+	// a failure is reported by an event of its own type
+	fake := shelltest.NewUnstartedServer()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/add" {
+			fake.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Name": "data", "Bytes": 10}` + "\n" + `{"Name": "data", "Hash": "bafkqaaa", "Size": "nope"}` + "\n"))
+	}))
+	defer srv.Close()
+	events, err = NewShell(srv.URL).AddStream(context.Background(), bytes.NewReader(data))
+	is.Nil(err)
+	ev = <-events
+	is.Equal(ev.Type, AddProgress)
+	is.Nil(ev.Err)
+	ev = <-events
+	is.Equal(ev.Type, AddError)
+	is.Err(ev.Err)
+	_, ok = <-events
+	is.False(ok)
+}
+
+func TestAddDirStream(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+
+	events, err := s.AddDirStream(context.Background(), "./testdata")
+	is.Nil(err)
+
+	entries := map[string]string{}
+	var root AddEvent
+	for ev := range events {
+		is.Nil(ev.Err)
+		switch ev.Type {
+		case AddEntry:
+			entries[ev.Name] = ev.Hash
+		case AddRoot:
+			root = ev
+		}
+	}
+	is.Equal(len(entries), 7)
+	is.Equal(entries["testdata/about"], "QmZTR5bcpQD7cFgTorqxZDYaew1Wqgfbd2ud9QqGPAkK2V")
+	is.Equal(root.Name, "testdata")
+	is.Equal(root.Hash, examplesHash)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err = s.AddDirStream(ctx, "./testdata")
+	is.Nil(err)
+	cancel()
+	for range events {
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	data := randString(1 << 20)
+	def, err := s.Add(strings.NewReader(data), OnlyHash(true))
+	is.Nil(err)
+	for _, opt := range []AddOpts{ChunkerSize(1 << 10), ChunkerRabin(1<<10, 1<<12, 1<<14), ChunkerBuzhash(), Trickle(true)} {
+		mhash, err := s.Add(strings.NewReader(data), opt)
+		is.Nil(err)
+		is.NotEqual(mhash, def)
+
+		rc, err := s.Cat(mhash)
+		is.Nil(err)
+		out, err := io.ReadAll(rc)
+		is.Nil(err)
+		is.Equal(string(out), data)
+	}
+
+	mhash, err := s.Add(strings.NewReader("inlined"), Inline(true), CidVersion(1))
+	is.Nil(err)
+	is.Equal(mhash, "bafkqab3jnzwgs3tfmq")
+	mhash, err = s.Add(strings.NewReader("not inlined"), Inline(true), InlineLimit(4), CidVersion(1))
+	is.Nil(err)
+	is.True(strings.HasPrefix(mhash, "bafkrei"))
+
+	mhash, err = s.AddDir("./testdata", WrapWithDirectory(true))
+	is.Nil(err)
+	list, err := s.List(mhash)
+	is.Nil(err)
+	is.Equal(len(list), 1)
+	is.Equal(list[0].Name, "testdata")
+	is.Equal(list[0].Hash, examplesHash)
+
+	mfsPath := "/addtofiles-" + randString(8)
+	mhash, err = s.Add(strings.NewReader(data), ToFiles(mfsPath))
+	is.Nil(err)
+	defer s.FilesRm(ctx, mfsPath, true)
+	stat, err := s.FilesStat(ctx, mfsPath)
+	is.Nil(err)
+	is.Equal(stat.Hash, mhash)
+}
+
+func TestAddOptionsValidation(t *testing.T) {
+	is := is.New(t)
+	// options are checked before anything is sent
+	s := NewShell("0.0.0.0:1234")
+
+	for _, opts := range [][]AddOpts{
+		{Chunker("size-0")},
+		{Chunker("size-2097152")},
+		{Chunker("rabin-64-32-128")},
+		{Chunker("fastcdc")},
+		{Hash("md4")},
+		{CidVersion(2)},
+		{InlineLimit(0)},
+		{ToFiles("relative/path")},
+		{Mode(os.ModeDir | 0o755)},
+		{Mtime(time.Time{})},
+		{Ignore("multi\nline")},
+		{IgnoreRulesPath("./testdata/does-not-exist")},
+		{NoCopy(true), RawLeaves(false)},
+		{Hash("sha3-256"), CidVersion(0)},
+		{OnlyHash(true), ToFiles("/foo")},
+	} {
+		_, err := s.Add(strings.NewReader("data"), opts...)
+		is.Err(err)
+		is.False(errors.Is(err, ErrDaemonUnreachable))
+	}
+}
+
+func TestAddDirFilter(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.log", ".hidden"} {
+		is.Nil(os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644))
+	}
+	rules := filepath.Join(t.TempDir(), "rules")
+	is.Nil(os.WriteFile(rules, []byte("a.*\n"), 0o644))
+
+	entries := func(opts ...AddOpts) []string {
+		events, err := s.AddDirStream(ctx, dir, opts...)
+		is.Nil(err)
+		var names []string
+		for ev := range events {
+			is.Nil(ev.Err)
+			if ev.Type == AddEntry {
+				names = append(names, filepath.Base(ev.Name))
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	is.Equal(entries(), []string{"a.txt", "b.log"})
+	is.Equal(entries(Hidden(true)), []string{".hidden", "a.txt", "b.log"})
+	is.Equal(entries(Ignore("*.log")), []string{"a.txt"})
+	is.Equal(entries(Ignore("*.log"), Ignore("a.txt"), Hidden(true)), []string{".hidden"})
+	is.Equal(entries(IgnoreRulesPath(rules)), []string{"b.log"})
+}
+
+func TestAddDirSelection(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":        "build/\n*.o\n",
+		"main.c":            "int main;",
+		"main.o":            "obj",
+		"build/out":         "out",
+		"docs/.ipfsignore":  "draft.md\n",
+		"docs/index.md":     "index",
+		"docs/draft.md":     "draft",
+		"docs/img/logo.png": "png",
+		"outside/file":      "outside",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		is.Nil(os.MkdirAll(filepath.Dir(p), 0o755))
+		is.Nil(os.WriteFile(p, []byte(content), 0o644))
+	}
+	is.Nil(os.Symlink("main.c", filepath.Join(dir, "link.c")))
+	is.Nil(os.Symlink("../outside", filepath.Join(dir, "docs", "linked")))
+	is.Nil(os.Symlink("..", filepath.Join(dir, "docs", "parent")))
+
+	files := func(opts ...AddOpts) []string {
+		events, err := s.AddDirStream(ctx, dir, append(opts, Exclude("outside"))...)
+		is.Nil(err)
+		var names []string
+		for ev := range events {
+			is.Nil(ev.Err)
+			if ev.Type == AddEntry && !strings.HasSuffix(ev.Name, "/docs") {
+				names = append(names, strings.TrimPrefix(ev.Name, filepath.Base(dir)+"/"))
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	is.Equal(files(Symlinks(SkipSymlinks)), []string{
+		"build", "build/out", "docs/draft.md", "docs/img", "docs/img/logo.png", "docs/index.md", "main.c", "main.o",
+	})
+	is.Equal(files(Symlinks(SkipSymlinks), IgnoreFiles(".gitignore", ".ipfsignore")), []string{
+		"docs/img", "docs/img/logo.png", "docs/index.md", "main.c",
+	})
+	is.Equal(files(Symlinks(SkipSymlinks), Include("*.md", "docs/img/*")), []string{
+		"build", "docs/draft.md", "docs/img", "docs/img/logo.png", "docs/index.md",
+	})
+	is.Equal(files(Symlinks(SkipSymlinks), Exclude("docs/*.md", "build")), []string{
+		"docs/img", "docs/img/logo.png", "main.c", "main.o",
+	})
+	// preserved symlinks are added as such, followed ones as what they
+	// point to
+	preserved := files(Include("*.c"), Exclude("docs"))
+	is.Equal(preserved, []string{"build", "link.c", "main.c"})
+	followed := files(Include("*.c", "file"), Exclude("parent"), Symlinks(FollowSymlinks))
+	is.Equal(followed, []string{"build", "docs/img", "docs/linked", "docs/linked/file", "link.c", "main.c"})
+
+	_, err := s.AddDir(dir, Symlinks(FollowSymlinks))
+	is.Err(err)
+
+	mhash, err := ComputeDirCID(dir, Symlinks(FollowSymlinks), Exclude("parent"), Include("*.c"))
+	is.Nil(err)
+	expected, err := s.AddDir(dir, Symlinks(FollowSymlinks), Exclude("parent"), Include("*.c"), OnlyHash(true))
+	is.Nil(err)
+	is.Equal(mhash, expected)
+
+	_, err = s.AddDir(dir, Include("["))
+	is.Err(err)
+	_, err = s.AddDir(dir, IgnoreFiles("a/b"))
+	is.Err(err)
+}
+
 func TestAddDirOffline(t *testing.T) {
 	is := is.New(t)
 	s := NewShell("0.0.0.0:1234") // connect to an invalid address
@@ -167,6 +436,25 @@ func TestCat(t *testing.T) {
 	is.Equal(fmt.Sprintf("%x", md5.Sum(nil)), "3fdcaad186e79983a6920b4c7eeda949")
 }
 
+func TestCatCtx(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+
+	rc, err := s.CatCtx(context.Background(), fmt.Sprintf("/ipfs/%s/readme", examplesHash))
+	is.Nil(err)
+	rc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.CatCtx(ctx, fmt.Sprintf("/ipfs/%s/readme", examplesHash))
+	is.True(errors.Is(err, context.Canceled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	is.False(s.IsUpCtx(ctx))
+}
+
 func TestList(t *testing.T) {
 	is := is.New(t)
 	s := NewShell(shellUrl)
@@ -339,6 +627,168 @@ func accumulatePins(pinChan <-chan PinStreamInfo) map[string]string {
 	return pins
 }
 
+func TestPinNames(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+	name := "named-" + randString(8)
+
+	h, err := s.Add(bytes.NewBufferString("go-ipfs-api named pin "+name), Pin(false))
+	is.Nil(err)
+	is.Nil(s.PinCtx(ctx, h, PinOpts.Name(name), PinOpts.Recursive(false)))
+
+	pins, err := s.PinsOfType(ctx, DirectPin, PinOpts.Name(name[:10]))
+	is.Nil(err)
+	is.Equal(pins, map[string]PinInfo{h: {Type: string(DirectPin), Name: name}})
+
+	pins, err = s.PinsOfType(ctx, DirectPin, PinOpts.Name(name+"-other"))
+	is.Nil(err)
+	is.Equal(len(pins), 0)
+
+	pins, err = s.PinsOfType(ctx, DirectPin)
+	is.Nil(err)
+	is.Equal(pins[h], PinInfo{Type: string(DirectPin)})
+
+	pinChan, err := s.PinsStream(ctx, PinOpts.Name(name))
+	is.Nil(err)
+	var streamed []PinStreamInfo
+	for pin := range pinChan {
+		streamed = append(streamed, pin)
+	}
+	is.Equal(streamed, []PinStreamInfo{{Cid: h, Type: string(DirectPin), Name: name}})
+
+	is.Nil(s.UnpinCtx(ctx, h))
+}
+
+func TestPinUpdate(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	from, err := s.Add(bytes.NewBufferString("go-ipfs-api pin update from " + randString(8)))
+	is.Nil(err)
+	to, err := s.Add(bytes.NewBufferString("go-ipfs-api pin update to "+randString(8)), Pin(false))
+	is.Nil(err)
+
+	is.Nil(s.PinUpdate(from, to, false))
+	pins, err := s.PinsOfType(ctx, RecursivePin)
+	is.Nil(err)
+	_, ok := pins[from]
+	is.True(ok)
+	_, ok = pins[to]
+	is.True(ok)
+
+	is.Nil(s.Unpin(to))
+	is.Nil(s.PinUpdateCtx(ctx, from, to, true))
+	pins, err = s.PinsOfType(ctx, RecursivePin)
+	is.Nil(err)
+	_, ok = pins[from]
+	is.False(ok)
+	_, ok = pins[to]
+	is.True(ok)
+
+	// from must be pinned recursively
+	is.NotNil(s.PinUpdate(from, to, true))
+}
+
+func TestPinVerify(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	h, err := s.Add(bytes.NewBufferString("go-ipfs-api pin verify " + randString(8)))
+	is.Nil(err)
+
+	results, err := s.PinVerify(ctx, true)
+	is.Nil(err)
+	found := false
+	for res := range results {
+		is.Nil(res.Err)
+		if res.Cid == h {
+			found = true
+			is.True(res.Ok)
+		}
+	}
+	is.True(found)
+
+	// broken pins and errors, as reported by the daemon
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"Cid":"QmBroken","BadNodes":[{"Cid":"QmMissing","Err":"not found"}]}`)
+		fmt.Fprintln(w, `{"Err":"pinner failed"}`)
+	}))
+	defer srv.Close()
+
+	results, err = NewShell(srv.URL).PinVerify(ctx, false)
+	is.Nil(err)
+	res := <-results
+	is.Equal(res, PinVerifyResult{Cid: "QmBroken", BadNodes: []PinBadNode{{Cid: "QmMissing", Err: "not found"}}})
+	res = <-results
+	is.Equal(res.Err.Error(), "pinner failed")
+	_, ok := <-results
+	is.False(ok)
+}
+
+func TestPinAdd(t *testing.T) {
+	is := is.New(t)
+	s := NewShell(shellUrl)
+	ctx := context.Background()
+
+	h, err := s.AddDir("./testdata", Pin(false))
+	is.Nil(err)
+
+	events, err := s.PinAdd(ctx, h)
+	is.Nil(err)
+	progress := 0
+	var last PinAddEvent
+	for ev := range events {
+		is.Nil(ev.Err)
+		if ev.Pins == nil {
+			is.True(ev.Progress > progress)
+			progress = ev.Progress
+		}
+		last = ev
+	}
+	is.True(progress > 1)
+	is.Equal(last.Pins, []string{h})
+
+	pins, err := s.PinsOfType(ctx, RecursivePin)
+	is.Nil(err)
+	_, ok := pins[h]
+	is.True(ok)
+
+	// cancelling aborts the request
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for i := 1; ; i++ {
+			fmt.Fprintf(w, "{\"Progress\":%d}\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				close(aborted)
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	cctx, cancel := context.WithCancel(ctx)
+	events, err = NewShell(srv.URL).PinAdd(cctx, h)
+	is.Nil(err)
+	ev := <-events
+	is.Equal(ev.Progress, 1)
+	cancel()
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not aborted")
+	}
+	for range events {
+	}
+}
+
 func TestPatch_rmLink(t *testing.T) {
 	is := is.New(t)
 	s := NewShell(shellUrl)
@@ -496,7 +946,7 @@ func TestDagImportMultipleCARs(t *testing.T) {
 		files.FileEntry("", files.NewReaderFile(bytes.NewReader(carFile2))),
 	})
 
-	fileReader, err := s.newMultiFileReader(slf)
+	fileReader, err := s.newMultiFileReader(context.Background(), slf)
 	is.Nil(err)
 
 	dagImported, err := s.DagImportWithOpts(
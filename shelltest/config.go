@@ -0,0 +1,364 @@
+package shelltest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultConfig is the config of a freshly initialized daemon, without its
+// identity.
+const defaultConfig = `{
+	"Identity": {},
+	"Datastore": {
+		"StorageMax": "10GB",
+		"StorageGCWatermark": 90,
+		"GCPeriod": "1h",
+		"Spec": {
+			"mounts": [
+				{
+					"child": {"path": "blocks", "shardFunc": "/repo/flatfs/shard/v1/next-to-last/2", "sync": true, "type": "flatfs"},
+					"mountpoint": "/blocks",
+					"prefix": "flatfs.datastore",
+					"type": "measure"
+				},
+				{
+					"child": {"compression": "none", "path": "datastore", "type": "levelds"},
+					"mountpoint": "/",
+					"prefix": "leveldb.datastore",
+					"type": "measure"
+				}
+			],
+			"type": "mount"
+		},
+		"HashOnRead": false,
+		"BloomFilterSize": 0
+	},
+	"Addresses": {
+		"Swarm": ["/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001", "/ip4/0.0.0.0/udp/4001/quic-v1", "/ip6/::/udp/4001/quic-v1"],
+		"Announce": [],
+		"AppendAnnounce": [],
+		"NoAnnounce": [],
+		"API": "/ip4/127.0.0.1/tcp/5001",
+		"Gateway": "/ip4/127.0.0.1/tcp/8080"
+	},
+	"Mounts": {"IPFS": "/ipfs", "IPNS": "/ipns", "FuseAllowOther": false},
+	"Discovery": {"MDNS": {"Enabled": true}},
+	"Routing": {"AcceleratedDHTClient": false, "Methods": null, "Routers": null},
+	"Ipns": {"RepublishPeriod": "", "RecordLifetime": "", "ResolveCacheSize": 128},
+	"Bootstrap": [
+		"/dnsaddr/bootstrap.libp2p.io/p2p/QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN",
+		"/dnsaddr/bootstrap.libp2p.io/p2p/QmQCU2EcMqAqQPR2i9bChDtGNJchTbq5TbXJJ16u19uLTa"
+	],
+	"Gateway": {
+		"HTTPHeaders": {},
+		"RootRedirect": "",
+		"PathPrefixes": [],
+		"APICommands": [],
+		"NoFetch": false,
+		"NoDNSLink": false,
+		"DeserializedResponses": null,
+		"PublicGateways": null
+	},
+	"API": {"HTTPHeaders": {}},
+	"Swarm": {
+		"AddrFilters": null,
+		"DisableBandwidthMetrics": false,
+		"DisableNatPortMap": false,
+		"RelayClient": {},
+		"RelayService": {},
+		"Transports": {"Network": {}, "Security": {}, "Multiplexers": {}},
+		"ConnMgr": {},
+		"ResourceMgr": {}
+	},
+	"AutoNAT": {},
+	"Pubsub": {"Router": "", "DisableSigning": false},
+	"Peering": {"Peers": null},
+	"DNS": {"Resolvers": {}},
+	"Migration": {"DownloadSources": [], "Keep": ""},
+	"Provider": {"Strategy": ""},
+	"Reprovider": {},
+	"Experimental": {
+		"FilestoreEnabled": false,
+		"UrlstoreEnabled": false,
+		"GraphsyncEnabled": false,
+		"Libp2pStreamMounting": false,
+		"P2pHttpProxy": false,
+		"StrategicProviding": false,
+		"OptimisticProvide": false,
+		"OptimisticProvideJobsPoolSize": 0
+	},
+	"Plugins": {"Plugins": null},
+	"Pinning": {"RemoteServices": {}},
+	"Internal": {}
+}`
+
+func newConfig(peerID string) map[string]interface{} {
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(defaultConfig), &cfg); err != nil {
+		panic(fmt.Sprintf("shelltest: invalid default config: %s", err))
+	}
+	cfg["Identity"] = map[string]interface{}{"PeerID": peerID}
+	return cfg
+}
+
+// copyConfig returns a deep copy of a config.
+func copyConfig(cfg map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("shelltest: invalid config: %s", err))
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		panic(fmt.Sprintf("shelltest: invalid config: %s", err))
+	}
+	return out
+}
+
+// showConfig returns the config as shown by the daemon, listing the remote
+// pinning services without their keys.
+func (s *Server) showConfig() map[string]interface{} {
+	cfg := copyConfig(s.config)
+	services := make(map[string]interface{}, len(s.remote))
+	for name, svc := range s.remote {
+		services[name] = map[string]interface{}{
+			"API": map[string]interface{}{"Endpoint": svc.endpoint},
+			"Policies": map[string]interface{}{
+				"MFS": map[string]interface{}{"Enable": false, "PinName": "", "RepinInterval": ""},
+			},
+		}
+	}
+	cfg["Pinning"] = map[string]interface{}{"RemoteServices": services}
+	return cfg
+}
+
+func configGet(cfg map[string]interface{}, key string) (interface{}, error) {
+	var cursor interface{} = cfg
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		m, ok := cursor.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s key is not a map", strings.Join(parts[:i], "."))
+		}
+		if cursor, ok = m[part]; !ok {
+			return nil, fmt.Errorf("%s not found", strings.Join(parts[:i+1], "."))
+		}
+	}
+	return cursor, nil
+}
+
+func configSet(cfg map[string]interface{}, key string, value interface{}) error {
+	var cursor interface{} = cfg
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		m, ok := cursor.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s key is not a map", strings.Join(parts[:i], "."))
+		}
+		if i == len(parts)-1 {
+			m[part] = value
+			break
+		}
+		if cursor, ok = m[part]; !ok || cursor == nil {
+			m[part] = map[string]interface{}{}
+			cursor = m[part]
+		}
+	}
+	return nil
+}
+
+type configField struct {
+	Key   string
+	Value interface{}
+}
+
+func (s *Server) configCmd(req *request, res *response) error {
+	key, err := req.arg(0, "key")
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(key) {
+	case "identity", "identity.privkey":
+		return errors.New("cannot show or change private key through API")
+	}
+	if strings.HasPrefix(key, "Pinning.") {
+		return clientError("shelltest: Pinning can only be changed with pin/remote/service")
+	}
+
+	if len(req.args) > 1 {
+		value, err := configValue(req)
+		if err != nil {
+			return err
+		}
+		if err := configSet(s.config, key, value); err != nil {
+			return fmt.Errorf("failed to set config value: %s (maybe use --json?)", err)
+		}
+	}
+
+	value, err := configGet(s.showConfig(), key)
+	if err != nil {
+		return fmt.Errorf("failed to get config value: %q", err)
+	}
+	return res.emit(configField{Key: key, Value: value})
+}
+
+// configValue returns the value to set, according to the json and bool
+// options.
+func configValue(req *request) (interface{}, error) {
+	raw := req.args[1]
+	isJSON, err := req.boolOption("json", false)
+	if err != nil {
+		return nil, err
+	}
+	isBool, err := req.boolOption("bool", false)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal json. %s", err)
+		}
+		return v, nil
+	case isBool:
+		return raw == "true", nil
+	default:
+		return raw, nil
+	}
+}
+
+func (s *Server) configShow(req *request, res *response) error {
+	return res.emit(s.showConfig())
+}
+
+func (s *Server) configReplace(req *request, res *response) error {
+	f, err := req.file()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var cfg map[string]interface{}
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return errors.New("failed to decode file as config")
+	}
+	if pk, _ := configGet(cfg, "Identity.PrivKey"); pk != nil && pk != "" {
+		return errors.New("setting private key with API is not supported")
+	}
+
+	// remote pinning services are kept apart, see showConfig
+	cfg["Pinning"] = map[string]interface{}{"RemoteServices": map[string]interface{}{}}
+	s.config = cfg
+	return nil
+}
+
+var serverFilters = []string{
+	"/ip4/10.0.0.0/ipcidr/8",
+	"/ip4/100.64.0.0/ipcidr/10",
+	"/ip4/169.254.0.0/ipcidr/16",
+	"/ip4/172.16.0.0/ipcidr/12",
+	"/ip4/192.0.0.0/ipcidr/24",
+	"/ip4/192.0.2.0/ipcidr/24",
+	"/ip4/192.168.0.0/ipcidr/16",
+	"/ip4/198.18.0.0/ipcidr/15",
+	"/ip4/198.51.100.0/ipcidr/24",
+	"/ip4/203.0.113.0/ipcidr/24",
+	"/ip4/240.0.0.0/ipcidr/4",
+	"/ip6/100::/ipcidr/64",
+	"/ip6/2001:2::/ipcidr/48",
+	"/ip6/2001:db8::/ipcidr/32",
+	"/ip6/fc00::/ipcidr/7",
+	"/ip6/fe80::/ipcidr/10",
+}
+
+// appendFilters adds the server filters missing from the list at key.
+func appendFilters(cfg map[string]interface{}, key string) {
+	list, _ := configGet(cfg, key)
+	values, _ := list.([]interface{})
+	have := make(map[interface{}]bool, len(values))
+	for _, v := range values {
+		have[v] = true
+	}
+	for _, f := range serverFilters {
+		if !have[f] {
+			values = append(values, f)
+		}
+	}
+	configSet(cfg, key, values)
+}
+
+// removeFilters removes the server filters from the list at key.
+func removeFilters(cfg map[string]interface{}, key string) {
+	list, _ := configGet(cfg, key)
+	values, _ := list.([]interface{})
+	filters := make(map[interface{}]bool, len(serverFilters))
+	for _, f := range serverFilters {
+		filters[f] = true
+	}
+	kept := []interface{}{}
+	for _, v := range values {
+		if !filters[v] {
+			kept = append(kept, v)
+		}
+	}
+	configSet(cfg, key, kept)
+}
+
+// profiles are the config profiles supported by the fake server.
+var profiles = map[string]func(cfg map[string]interface{}){
+	"server": func(cfg map[string]interface{}) {
+		appendFilters(cfg, "Addresses.NoAnnounce")
+		appendFilters(cfg, "Swarm.AddrFilters")
+		configSet(cfg, "Discovery.MDNS.Enabled", false)
+		configSet(cfg, "Swarm.DisableNatPortMap", true)
+	},
+	"local-discovery": func(cfg map[string]interface{}) {
+		removeFilters(cfg, "Addresses.NoAnnounce")
+		removeFilters(cfg, "Swarm.AddrFilters")
+		configSet(cfg, "Discovery.MDNS.Enabled", true)
+		configSet(cfg, "Swarm.DisableNatPortMap", false)
+	},
+	"test": func(cfg map[string]interface{}) {
+		configSet(cfg, "Addresses.API", "/ip4/127.0.0.1/tcp/0")
+		configSet(cfg, "Addresses.Gateway", "/ip4/127.0.0.1/tcp/0")
+		configSet(cfg, "Addresses.Swarm", []interface{}{"/ip4/127.0.0.1/tcp/0"})
+		configSet(cfg, "Swarm.DisableNatPortMap", true)
+		configSet(cfg, "Bootstrap", []interface{}{})
+		configSet(cfg, "Discovery.MDNS.Enabled", false)
+	},
+	"lowpower": func(cfg map[string]interface{}) {
+		configSet(cfg, "Routing.Type", "dhtclient")
+		configSet(cfg, "AutoNAT.ServiceMode", "disabled")
+		configSet(cfg, "Reprovider.Interval", "0s")
+		configSet(cfg, "Swarm.ConnMgr.Type", "basic")
+		configSet(cfg, "Swarm.ConnMgr.LowWater", float64(20))
+		configSet(cfg, "Swarm.ConnMgr.HighWater", float64(40))
+		configSet(cfg, "Swarm.ConnMgr.GracePeriod", "1m0s")
+	},
+}
+
+func (s *Server) configProfileApply(req *request, res *response) error {
+	name, err := req.arg(0, "profile")
+	if err != nil {
+		return err
+	}
+	transform, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("%s is not a profile", name)
+	}
+	dryRun, err := req.boolOption("dry-run", false)
+	if err != nil {
+		return err
+	}
+
+	oldCfg := copyConfig(s.config)
+	newCfg := copyConfig(s.config)
+	transform(newCfg)
+	if !dryRun {
+		s.config = copyConfig(newCfg)
+	}
+	return res.emit(struct{ OldCfg, NewCfg map[string]interface{} }{oldCfg, newCfg})
+}
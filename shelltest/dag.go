@@ -0,0 +1,651 @@
+package shelltest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	car "github.com/ipld/go-car/v2"
+	_ "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	mc "github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+)
+
+// blockSizeLimit is the largest block accepted without allow-big-block.
+const blockSizeLimit = 1024 * 1024
+
+var errBigBlock = errors.New("produced block is over 1MiB: big blocks can't be exchanged with other peers. consider using UnixFS for automatic chunking of bigger files, or pass --allow-big-block to override")
+
+type cidOutput struct {
+	Cid string `json:"/"`
+}
+
+func (s *Server) linkSystem(ctx context.Context) linking.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(_ linking.LinkContext, lnk datamodel.Link) (io.Reader, error) {
+		blk, err := s.bstore.Get(ctx, lnk.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(blk.RawData()), nil
+	}
+	return lsys
+}
+
+// putBlock stores a block, enforcing the block size limit.
+func (s *Server) putBlock(ctx context.Context, blk blocks.Block, allowBig bool) error {
+	if !allowBig && len(blk.RawData()) > blockSizeLimit {
+		return errBigBlock
+	}
+	return s.bstore.Put(ctx, blk)
+}
+
+func codecByName(name string) (mc.Code, error) {
+	var code mc.Code
+	if err := code.Set(name); err != nil {
+		return 0, clientError("unknown codec %q", name)
+	}
+	return code, nil
+}
+
+func (s *Server) dagPut(req *request, res *response) error {
+	inputCodec, err := codecByName(req.stringOption("input-codec", "dag-json"))
+	if err != nil {
+		return err
+	}
+	storeCodec, err := codecByName(req.stringOption("store-codec", "dag-cbor"))
+	if err != nil {
+		return err
+	}
+	hashFun := req.stringOption("hash", "sha2-256")
+	mhType, ok := mh.Names[hashFun]
+	if !ok {
+		return clientError("%s in not a valid multihash name", hashFun)
+	}
+	pin, err := req.boolOption("pin", false)
+	if err != nil {
+		return err
+	}
+	allowBig, err := req.boolOption("allow-big-block", false)
+	if err != nil {
+		return err
+	}
+
+	decode, err := multicodec.LookupDecoder(uint64(inputCodec))
+	if err != nil {
+		return err
+	}
+	encode, err := multicodec.LookupEncoder(uint64(storeCodec))
+	if err != nil {
+		return err
+	}
+
+	f, err := req.file()
+	if err != nil {
+		return err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decode(nb, f); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := encode(nb.Build(), &buf); err != nil {
+		return err
+	}
+
+	prefix := cid.Prefix{Version: 1, Codec: uint64(storeCodec), MhType: mhType, MhLength: -1}
+	c, err := prefix.Sum(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	blk, err := blocks.NewBlockWithCid(buf.Bytes(), c)
+	if err != nil {
+		return err
+	}
+	if err := s.putBlock(req.Context(), blk, allowBig); err != nil {
+		return err
+	}
+	if pin {
+		s.pins[c] = "recursive"
+	}
+
+	return res.emit(struct{ Cid cidOutput }{cidOutput{c.String()}})
+}
+
+// loadPath loads the node at ref, a CID followed by an optional path, which
+// is traversed through the IPLD data model following links along the way.
+func (s *Server) loadPath(ctx context.Context, ref string) (datamodel.Node, error) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(ref, "/ipfs/"), "/"), "/")
+	c, err := cid.Decode(segments[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", ref, err)
+	}
+
+	lsys := s.linkSystem(ctx)
+	nd, err := lsys.Load(linking.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, basicnode.Prototype.Any)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seg := range segments[1:] {
+		if seg == "" {
+			continue
+		}
+		nd, err = nd.LookupBySegment(datamodel.PathSegmentOfString(seg))
+		if err != nil {
+			return nil, fmt.Errorf("no link named %q under %s", seg, c)
+		}
+		if nd.Kind() == datamodel.Kind_Link {
+			lnk, _ := nd.AsLink()
+			c = lnk.(cidlink.Link).Cid
+			nd, err = lsys.Load(linking.LinkContext{Ctx: ctx}, lnk, basicnode.Prototype.Any)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nd, nil
+}
+
+func (s *Server) dagGet(req *request, res *response) error {
+	ref, err := req.arg(0, "ref")
+	if err != nil {
+		return err
+	}
+	outputCodec, err := codecByName(req.stringOption("output-codec", "dag-json"))
+	if err != nil {
+		return err
+	}
+	encode, err := multicodec.LookupEncoder(uint64(outputCodec))
+	if err != nil {
+		return err
+	}
+
+	nd, err := s.loadPath(req.Context(), ref)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if outputCodec == mc.DagJson {
+		// match the daemon, which emits a trailing newline
+		err = dagjson.Encode(nd, &buf)
+		buf.WriteByte('\n')
+	} else {
+		err = encode(nd, &buf)
+	}
+	if err != nil {
+		return err
+	}
+	return res.stream(&buf)
+}
+
+type carImportRoot struct {
+	Root struct {
+		Cid         cidOutput
+		PinErrorMsg string
+	}
+}
+
+type carImportStats struct {
+	Stats struct {
+		BlockCount      uint64
+		BlockBytesCount uint64
+	}
+}
+
+func (s *Server) dagImport(req *request, res *response) error {
+	pinRoots, err := req.boolOption("pin-roots", true)
+	if err != nil {
+		return err
+	}
+	stats, err := req.boolOption("stats", false)
+	if err != nil {
+		return err
+	}
+	allowBig, err := req.boolOption("allow-big-block", false)
+	if err != nil {
+		return err
+	}
+
+	dir, err := req.files()
+	if err != nil {
+		return err
+	}
+
+	var (
+		roots []cid.Cid
+		out   carImportStats
+	)
+	seen := cid.NewSet()
+	it := dir.Entries()
+	for it.Next() {
+		f, ok := it.Node().(io.Reader)
+		if !ok {
+			return clientError("expected a CAR file")
+		}
+		br, err := car.NewBlockReader(f)
+		if err != nil {
+			return err
+		}
+		for _, r := range br.Roots {
+			if seen.Visit(r) {
+				roots = append(roots, r)
+			}
+		}
+		for {
+			blk, err := br.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.putBlock(req.Context(), blk, allowBig); err != nil {
+				return err
+			}
+			out.Stats.BlockCount++
+			out.Stats.BlockBytesCount += uint64(len(blk.RawData()))
+		}
+	}
+	if it.Err() != nil {
+		return it.Err()
+	}
+
+	if pinRoots {
+		for _, r := range roots {
+			var root carImportRoot
+			root.Root.Cid.Cid = r.String()
+			err := s.walk(req.Context(), r, func(ipld.Node) error { return nil })
+			if err != nil {
+				root.Root.PinErrorMsg = err.Error()
+			} else {
+				s.pins[r] = "recursive"
+			}
+			if err := res.emit(root); err != nil {
+				return err
+			}
+		}
+	}
+	if stats {
+		return res.emit(out)
+	}
+	return nil
+}
+
+type blockStatOutput struct {
+	Key  string
+	Size int
+}
+
+func (s *Server) blockCid(req *request) (cid.Cid, error) {
+	p, err := req.arg(0, "cid")
+	if err != nil {
+		return cid.Undef, err
+	}
+	c, err := cid.Decode(strings.TrimPrefix(p, "/ipfs/"))
+	if err != nil {
+		return cid.Undef, clientError("invalid CID %q: %s", p, err)
+	}
+	return c, nil
+}
+
+func (s *Server) blockGet(req *request, res *response) error {
+	c, err := s.blockCid(req)
+	if err != nil {
+		return err
+	}
+	blk, err := s.bstore.Get(req.Context(), c)
+	if err != nil {
+		return err
+	}
+	return res.stream(bytes.NewReader(blk.RawData()))
+}
+
+func (s *Server) blockStat(req *request, res *response) error {
+	c, err := s.blockCid(req)
+	if err != nil {
+		return err
+	}
+	size, err := s.bstore.GetSize(req.Context(), c)
+	if err != nil {
+		return err
+	}
+	return res.emit(blockStatOutput{Key: c.String(), Size: size})
+}
+
+// blockPrefix returns the CID prefix selected by the cid-codec option or the
+// legacy format option of block/put.
+func blockPrefix(req *request) (cid.Prefix, error) {
+	mhType, ok := mh.Names[req.stringOption("mhtype", "sha2-256")]
+	if !ok {
+		return cid.Prefix{}, clientError("unrecognized multihash function: %s", req.stringOption("mhtype", ""))
+	}
+	mhLen, err := req.intOption("mhlen", -1)
+	if err != nil {
+		return cid.Prefix{}, err
+	}
+	prefix := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mhType, MhLength: int(mhLen)}
+
+	codec := req.stringOption("cid-codec", "")
+	format := req.stringOption("format", "")
+	switch {
+	case codec != "" && format != "":
+		return prefix, clientError("unable to use %q (deprecated) and a custom %q at the same time", "format", "cid-codec")
+	case codec != "":
+		code, err := codecByName(codec)
+		if err != nil {
+			return prefix, err
+		}
+		prefix.Codec = uint64(code)
+	case format == "v0" || format == "protobuf":
+		if mhType != mh.SHA2_256 || (mhLen != -1 && mhLen != 32) {
+			return prefix, clientError("only sha2-256 with default length is allowed for CIDv0")
+		}
+		prefix.Version = 0
+		prefix.Codec = cid.DagProtobuf
+	case format == "cbor":
+		prefix.Codec = cid.DagCBOR
+	case format != "":
+		code, err := codecByName(format)
+		if err != nil {
+			return prefix, err
+		}
+		prefix.Codec = uint64(code)
+	}
+	return prefix, nil
+}
+
+func (s *Server) blockPut(req *request, res *response) error {
+	prefix, err := blockPrefix(req)
+	if err != nil {
+		return err
+	}
+	pin, err := req.boolOption("pin", false)
+	if err != nil {
+		return err
+	}
+	allowBig, err := req.boolOption("allow-big-block", false)
+	if err != nil {
+		return err
+	}
+
+	dir, err := req.files()
+	if err != nil {
+		return err
+	}
+	it := dir.Entries()
+	for it.Next() {
+		f, ok := it.Node().(io.Reader)
+		if !ok {
+			return clientError("expected a regular file")
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		c, err := prefix.Sum(data)
+		if err != nil {
+			return err
+		}
+		blk, err := blocks.NewBlockWithCid(data, c)
+		if err != nil {
+			return err
+		}
+		if err := s.putBlock(req.Context(), blk, allowBig); err != nil {
+			return err
+		}
+		if pin {
+			s.pins[c] = "recursive"
+		}
+		if err := res.emit(blockStatOutput{Key: c.String(), Size: len(data)}); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+type removedBlock struct {
+	Hash  string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+func (s *Server) blockRm(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "cid")
+	}
+	force, err := req.boolOption("force", false)
+	if err != nil {
+		return err
+	}
+	quiet, err := req.boolOption("quiet", false)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range req.args {
+		c, err := cid.Decode(arg)
+		if err != nil {
+			return clientError("invalid CID %q: %s", arg, err)
+		}
+		out := removedBlock{Hash: c.String()}
+
+		pinType, err := s.pinned(req.Context(), c)
+		switch {
+		case err != nil:
+			out.Error = err.Error()
+		case pinType != "":
+			out.Error = "pinned: " + pinType
+		default:
+			if has, _ := s.bstore.Has(req.Context(), c); !has && !force {
+				out.Error = ipld.ErrNotFound{Cid: c}.Error()
+			} else if err := s.bstore.DeleteBlock(req.Context(), c); err != nil {
+				out.Error = err.Error()
+			}
+		}
+
+		// like the daemon, failures are only reported per block
+		if out.Error == "" && quiet {
+			continue
+		}
+		if err := res.emit(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) dagExport(req *request, res *response) error {
+	p, err := req.arg(0, "root")
+	if err != nil {
+		return err
+	}
+	root, err := cid.Decode(strings.TrimPrefix(p, "/ipfs/"))
+	if err != nil {
+		nd, err := s.resolve(req.Context(), p)
+		if err != nil {
+			return err
+		}
+		root = nd.Cid()
+	}
+	if has, err := s.bstore.Has(req.Context(), root); err != nil {
+		return err
+	} else if !has {
+		return fmt.Errorf("block was not found locally (offline): %s", ipld.ErrNotFound{Cid: root})
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		lsys := s.linkSystem(req.Context())
+		_, err := car.TraverseV1(req.Context(), &lsys, root, selectorparse.CommonSelector_ExploreAllRecursively, pw)
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+	return res.stream(pr)
+}
+
+// resolveDag resolves ref to the last block it reaches and the path left to
+// follow within that block, like the daemon's path resolver.
+func (s *Server) resolveDag(ctx context.Context, ref string) (cid.Cid, []string, error) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(ref, "/ipfs/"), "/"), "/")
+	c, err := cid.Decode(segments[0])
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("invalid path %q: %w", ref, err)
+	}
+	if c.Prefix().Codec == cid.DagProtobuf {
+		// unixfs paths follow named links
+		nd, err := s.resolve(ctx, ref)
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		return nd.Cid(), nil, nil
+	}
+
+	lsys := s.linkSystem(ctx)
+	nd, err := lsys.Load(linking.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, basicnode.Prototype.Any)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	var rem []string
+	for _, seg := range segments[1:] {
+		if seg == "" {
+			continue
+		}
+		nd, err = nd.LookupBySegment(datamodel.PathSegmentOfString(seg))
+		if err != nil {
+			return cid.Undef, nil, fmt.Errorf("no link named %q under %s", seg, c)
+		}
+		rem = append(rem, seg)
+		if nd.Kind() == datamodel.Kind_Link {
+			lnk, _ := nd.AsLink()
+			c, rem = lnk.(cidlink.Link).Cid, nil
+			nd, err = lsys.Load(linking.LinkContext{Ctx: ctx}, lnk, basicnode.Prototype.Any)
+			if err != nil {
+				return cid.Undef, nil, err
+			}
+		}
+	}
+	return c, rem, nil
+}
+
+type dagResolveOutput struct {
+	Cid     cidOutput
+	RemPath string
+}
+
+func (s *Server) dagResolve(req *request, res *response) error {
+	ref, err := req.arg(0, "ref")
+	if err != nil {
+		return err
+	}
+	c, rem, err := s.resolveDag(req.Context(), ref)
+	if err != nil {
+		return err
+	}
+	return res.emit(dagResolveOutput{Cid: cidOutput{c.String()}, RemPath: strings.Join(rem, "/")})
+}
+
+type dagStat struct {
+	Cid       string
+	Size      uint64 `json:",omitempty"`
+	NumBlocks int64  `json:",omitempty"`
+}
+
+type dagStatSummary struct {
+	UniqueBlocks int        `json:",omitempty"`
+	TotalSize    uint64     `json:",omitempty"`
+	SharedSize   uint64     `json:",omitempty"`
+	Ratio        float32    `json:",omitempty"`
+	DagStats     []*dagStat `json:",omitempty"`
+}
+
+func (s *Server) dagStat(req *request, res *response) error {
+	progress, err := req.boolOption("progress", true)
+	if err != nil {
+		return err
+	}
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "root")
+	}
+
+	ctx := req.Context()
+	lsys := s.linkSystem(ctx)
+	all := cid.NewSet()
+	var redundant uint64
+	out := dagStatSummary{DagStats: []*dagStat{}}
+	for _, ref := range req.args {
+		root, rem, err := s.resolveDag(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if len(rem) > 0 {
+			return errors.New("cannot return size for anything other than a DAG with a root CID")
+		}
+
+		stat := &dagStat{Cid: root.String()}
+		out.DagStats = append(out.DagStats, stat)
+		seen := cid.NewSet()
+		var visit func(c cid.Cid) error
+		visit = func(c cid.Cid) error {
+			if !seen.Visit(c) {
+				return nil
+			}
+			blk, err := s.bstore.Get(ctx, c)
+			if err != nil {
+				return err
+			}
+			size := uint64(len(blk.RawData()))
+			stat.Size += size
+			stat.NumBlocks++
+			if all.Visit(c) {
+				out.TotalSize += size
+			}
+			redundant += size
+			if progress {
+				if err := res.emit(out); err != nil {
+					return err
+				}
+			}
+
+			nd, err := lsys.Load(linking.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, basicnode.Prototype.Any)
+			if err != nil {
+				return err
+			}
+			links, err := traversal.SelectLinks(nd)
+			if err != nil {
+				return err
+			}
+			for _, l := range links {
+				if err := visit(l.(cidlink.Link).Cid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := visit(root); err != nil {
+			return fmt.Errorf("error traversing DAG: %w", err)
+		}
+	}
+
+	out.UniqueBlocks = all.Len()
+	out.Ratio = float32(redundant) / float32(out.TotalSize)
+	out.SharedSize = redundant - out.TotalSize
+	return res.emit(out)
+}
@@ -0,0 +1,577 @@
+package shelltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"strings"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+type filesStatOutput struct {
+	Hash           string
+	Size           uint64
+	CumulativeSize uint64
+	Blocks         int
+	Type           string
+	WithLocality   bool   `json:",omitempty"`
+	Local          bool   `json:",omitempty"`
+	SizeLocal      uint64 `json:",omitempty"`
+}
+
+// checkPath validates and cleans an MFS path.
+func checkPath(p string) (string, error) {
+	if len(p) == 0 {
+		return "", clientError("paths must not be empty")
+	}
+	if p[0] != '/' {
+		return "", clientError("paths must start with a leading slash")
+	}
+
+	cleaned := gopath.Clean(p)
+	if p[len(p)-1] == '/' && p != "/" {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+// pathArg returns the i-th argument as a checked MFS path, defaulting to the
+// root.
+func pathArg(req *request, i int) (string, error) {
+	if i >= len(req.args) {
+		return "/", nil
+	}
+	return checkPath(req.args[i])
+}
+
+// filesPrefix returns the CID builder selected by the cid-version and hash
+// options, or nil to inherit the one of the parent directory.
+func filesPrefix(req *request) (cid.Builder, error) {
+	prefix, err := cidPrefix(req)
+	if prefix == nil || err != nil {
+		return nil, err
+	}
+	return prefix, nil
+}
+
+func (s *Server) nodeFromPath(ctx context.Context, p string) (ipld.Node, error) {
+	if strings.HasPrefix(p, "/ipfs/") || strings.HasPrefix(p, "/ipns/") {
+		return s.resolve(ctx, p)
+	}
+	fsn, err := mfs.Lookup(s.files, p)
+	if err != nil {
+		return nil, err
+	}
+	return fsn.GetNode()
+}
+
+func (s *Server) parentDir(dir string) (*mfs.Directory, error) {
+	parent, err := mfs.Lookup(s.files, dir)
+	if err != nil {
+		return nil, err
+	}
+	pdir, ok := parent.(*mfs.Directory)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return pdir, nil
+}
+
+func (s *Server) ensureParents(p string, builder cid.Builder) error {
+	dir := gopath.Dir(p)
+	if dir == "/" {
+		return nil
+	}
+	return mfs.Mkdir(s.files, dir, mfs.MkdirOpts{
+		Mkparents:  true,
+		CidBuilder: builder,
+	})
+}
+
+func (s *Server) filesChcid(req *request, res *response) error {
+	p, err := pathArg(req, 0)
+	if err != nil {
+		return err
+	}
+	builder, err := filesPrefix(req)
+	if err != nil || builder == nil {
+		return err
+	}
+
+	fsn, err := mfs.Lookup(s.files, p)
+	if err != nil {
+		return err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("can only update directories")
+	}
+	dir.SetCidBuilder(builder)
+	_, err = mfs.FlushPath(req.Context(), s.files, p)
+	return err
+}
+
+func (s *Server) filesCp(req *request, res *response) error {
+	src, err := req.arg(0, "source")
+	if err != nil {
+		return err
+	}
+	dst, err := req.arg(1, "dest")
+	if err != nil {
+		return err
+	}
+	if src, err = checkPath(src); err != nil {
+		return err
+	}
+	if dst, err = checkPath(dst); err != nil {
+		return err
+	}
+	src = strings.TrimRight(src, "/")
+	if dst[len(dst)-1] == '/' {
+		dst += gopath.Base(src)
+	}
+	parents, err := req.boolOption("parents", false)
+	if err != nil {
+		return err
+	}
+
+	nd, err := s.nodeFromPath(req.Context(), src)
+	if err != nil {
+		return fmt.Errorf("cp: cannot get node from path %s: %s", src, err)
+	}
+	if parents {
+		if err := s.ensureParents(dst, nil); err != nil {
+			return err
+		}
+	}
+	if err := mfs.PutNode(s.files, dst, nd); err != nil {
+		return fmt.Errorf("cp: cannot put node in path %s: %s", dst, err)
+	}
+	_, err = mfs.FlushPath(req.Context(), s.files, dst)
+	return err
+}
+
+func (s *Server) filesFlush(req *request, res *response) error {
+	p, err := pathArg(req, 0)
+	if err != nil {
+		return err
+	}
+	nd, err := mfs.FlushPath(req.Context(), s.files, p)
+	if err != nil {
+		return err
+	}
+	return res.emit(struct{ Cid string }{nd.Cid().String()})
+}
+
+func (s *Server) filesLs(req *request, res *response) error {
+	p, err := pathArg(req, 0)
+	if err != nil {
+		return err
+	}
+	long, err := req.boolOption("long", false)
+	if err != nil {
+		return err
+	}
+
+	fsn, err := mfs.Lookup(s.files, p)
+	if err != nil {
+		return err
+	}
+
+	var out struct{ Entries []mfs.NodeListing }
+	switch fsn := fsn.(type) {
+	case *mfs.Directory:
+		if long {
+			if out.Entries, err = fsn.List(req.Context()); err != nil {
+				return err
+			}
+			break
+		}
+		names, err := fsn.ListNames(req.Context())
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			out.Entries = append(out.Entries, mfs.NodeListing{Name: name})
+		}
+	case *mfs.File:
+		entry := mfs.NodeListing{Name: gopath.Base(p)}
+		if long {
+			entry.Type = int(fsn.Type())
+			if entry.Size, err = fsn.Size(); err != nil {
+				return err
+			}
+			nd, err := fsn.GetNode()
+			if err != nil {
+				return err
+			}
+			entry.Hash = nd.Cid().String()
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return res.emit(out)
+}
+
+func (s *Server) filesMkdir(req *request, res *response) error {
+	p, err := req.arg(0, "path")
+	if err != nil {
+		return err
+	}
+	if p, err = checkPath(p); err != nil {
+		return err
+	}
+	parents, err := req.boolOption("parents", false)
+	if err != nil {
+		return err
+	}
+	builder, err := filesPrefix(req)
+	if err != nil {
+		return err
+	}
+
+	return mfs.Mkdir(s.files, p, mfs.MkdirOpts{
+		Mkparents:  parents,
+		Flush:      true,
+		CidBuilder: builder,
+	})
+}
+
+func (s *Server) filesMv(req *request, res *response) error {
+	src, err := req.arg(0, "source")
+	if err != nil {
+		return err
+	}
+	dst, err := req.arg(1, "dest")
+	if err != nil {
+		return err
+	}
+	if src, err = checkPath(src); err != nil {
+		return err
+	}
+	if dst, err = checkPath(dst); err != nil {
+		return err
+	}
+
+	if err := mfs.Mv(s.files, src, dst); err != nil {
+		return err
+	}
+	_, err = mfs.FlushPath(req.Context(), s.files, "/")
+	return err
+}
+
+func (s *Server) filesRead(req *request, res *response) error {
+	p, err := req.arg(0, "path")
+	if err != nil {
+		return err
+	}
+	if p, err = checkPath(p); err != nil {
+		return err
+	}
+	offset, err := req.intOption("offset", 0)
+	if err != nil {
+		return err
+	}
+	count, err := req.intOption("count", -1)
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		return fmt.Errorf("cannot specify negative offset")
+	}
+
+	fsn, err := mfs.Lookup(s.files, p)
+	if err != nil {
+		return err
+	}
+	fi, ok := fsn.(*mfs.File)
+	if !ok {
+		return fmt.Errorf("%s was not a file", p)
+	}
+	rfd, err := fi.Open(mfs.Flags{Read: true})
+	if err != nil {
+		return err
+	}
+	defer rfd.Close()
+
+	size, err := rfd.Size()
+	if err != nil {
+		return err
+	}
+	if offset > size {
+		return fmt.Errorf("offset was past end of file (%d > %d)", offset, size)
+	}
+	if _, err := rfd.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var r io.Reader = rfd
+	if count >= 0 {
+		r = io.LimitReader(r, count)
+	}
+	return res.stream(r)
+}
+
+func (s *Server) filesRm(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "path")
+	}
+	force, err := req.boolOption("force", false)
+	if err != nil {
+		return err
+	}
+	recursive, err := req.boolOption("recursive", false)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range req.args {
+		p, err := checkPath(p)
+		if err != nil {
+			return err
+		}
+		if err := s.removePath(p, force, recursive); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) removePath(p string, force, recursive bool) error {
+	if p == "/" {
+		return fmt.Errorf("cannot delete root")
+	}
+	p = strings.TrimSuffix(p, "/")
+	dir, name := gopath.Split(p)
+
+	pdir, err := s.parentDir(dir)
+	if err != nil {
+		if force && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if !force {
+		child, err := pdir.Child(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := child.(*mfs.Directory); ok && !recursive {
+			return fmt.Errorf("path is a directory, use -r to remove directories")
+		}
+	}
+
+	if err := pdir.Unlink(name); err != nil {
+		if force && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return pdir.Flush()
+}
+
+func (s *Server) filesStat(req *request, res *response) error {
+	p, err := req.arg(0, "path")
+	if err != nil {
+		return err
+	}
+	if p, err = checkPath(p); err != nil {
+		return err
+	}
+	withLocal, err := req.boolOption("with-local", false)
+	if err != nil {
+		return err
+	}
+
+	nd, err := s.nodeFromPath(req.Context(), p)
+	if err != nil {
+		return err
+	}
+	out, err := statNode(nd)
+	if err != nil {
+		return err
+	}
+
+	if withLocal {
+		out.WithLocality = true
+		out.Local = true
+		err := s.walk(req.Context(), nd.Cid(), func(nd ipld.Node) error {
+			out.SizeLocal += uint64(len(nd.RawData()))
+			return nil
+		})
+		if ipld.IsNotFound(err) {
+			out.Local = false
+		} else if err != nil {
+			return err
+		}
+	}
+	return res.emit(out)
+}
+
+func statNode(nd ipld.Node) (*filesStatOutput, error) {
+	cumulsize, err := nd.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := nd.(type) {
+	case *dag.ProtoNode:
+		d, err := ft.FSNodeFromBytes(n.Data())
+		if err != nil {
+			return nil, err
+		}
+		typ := "file"
+		if d.IsDir() {
+			typ = "directory"
+		}
+		return &filesStatOutput{
+			Hash:           nd.Cid().String(),
+			Blocks:         len(nd.Links()),
+			Size:           d.FileSize(),
+			CumulativeSize: cumulsize,
+			Type:           typ,
+		}, nil
+	case *dag.RawNode:
+		return &filesStatOutput{
+			Hash:           nd.Cid().String(),
+			Size:           cumulsize,
+			CumulativeSize: cumulsize,
+			Type:           "file",
+		}, nil
+	default:
+		return nil, fmt.Errorf("not unixfs node (proto or raw)")
+	}
+}
+
+func (s *Server) filesWrite(req *request, res *response) error {
+	p, err := req.arg(0, "path")
+	if err != nil {
+		return err
+	}
+	if p, err = checkPath(p); err != nil {
+		return err
+	}
+	create, err := req.boolOption("create", false)
+	if err != nil {
+		return err
+	}
+	parents, err := req.boolOption("parents", false)
+	if err != nil {
+		return err
+	}
+	truncate, err := req.boolOption("truncate", false)
+	if err != nil {
+		return err
+	}
+	offset, err := req.intOption("offset", 0)
+	if err != nil {
+		return err
+	}
+	count, err := req.intOption("count", -1)
+	if err != nil {
+		return err
+	}
+	rawLeaves, err := req.boolOption("raw-leaves", false)
+	if err != nil {
+		return err
+	}
+	builder, err := filesPrefix(req)
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		return fmt.Errorf("cannot have negative write offset")
+	}
+
+	data, err := req.file()
+	if err != nil {
+		return err
+	}
+
+	if parents {
+		if err := s.ensureParents(p, builder); err != nil {
+			return err
+		}
+	}
+
+	fi, err := s.fileHandle(p, create, builder)
+	if err != nil {
+		return err
+	}
+	if req.has("raw-leaves") {
+		fi.RawLeaves = rawLeaves
+	}
+
+	wfd, err := fi.Open(mfs.Flags{Write: true, Sync: true})
+	if err != nil {
+		return err
+	}
+	if truncate {
+		if err := wfd.Truncate(0); err != nil {
+			wfd.Close()
+			return err
+		}
+	}
+	if _, err := wfd.Seek(offset, io.SeekStart); err != nil {
+		wfd.Close()
+		return err
+	}
+
+	var r io.Reader = data
+	if count >= 0 {
+		r = io.LimitReader(r, count)
+	}
+	if _, err := io.Copy(wfd, r); err != nil {
+		wfd.Close()
+		return err
+	}
+	return wfd.Close()
+}
+
+func (s *Server) fileHandle(p string, create bool, builder cid.Builder) (*mfs.File, error) {
+	target, err := mfs.Lookup(s.files, p)
+	switch {
+	case err == nil:
+		fi, ok := target.(*mfs.File)
+		if !ok {
+			return nil, fmt.Errorf("%s was not a file", p)
+		}
+		return fi, nil
+	case errors.Is(err, os.ErrNotExist) && create:
+		dir, name := gopath.Split(p)
+		pdir, err := s.parentDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if builder == nil {
+			builder = pdir.GetCidBuilder()
+		}
+
+		nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+		if err := nd.SetCidBuilder(builder); err != nil {
+			return nil, err
+		}
+		if err := pdir.AddChild(name, nd); err != nil {
+			return nil, err
+		}
+		fsn, err := pdir.Child(name)
+		if err != nil {
+			return nil, err
+		}
+		fi, ok := fsn.(*mfs.File)
+		if !ok {
+			return nil, fmt.Errorf("%s was not a file", p)
+		}
+		return fi, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,286 @@
+package shelltest
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+type key struct {
+	name string
+	sk   crypto.PrivKey
+}
+
+func (k *key) peerID() (peer.ID, error) {
+	return peer.IDFromPrivateKey(k.sk)
+}
+
+// id formats the key identifier like the daemon does by default, as a
+// base36 libp2p-key CID.
+func (k *key) id() (string, error) {
+	pid, err := k.peerID()
+	if err != nil {
+		return "", err
+	}
+	return peer.ToCid(pid).StringOfBase(mbase.Base36)
+}
+
+type keyOutput struct {
+	Name string
+	Id   string
+}
+
+func (k *key) output() (*keyOutput, error) {
+	id, err := k.id()
+	if err != nil {
+		return nil, err
+	}
+	return &keyOutput{Name: k.name, Id: id}, nil
+}
+
+func (s *Server) findKey(name string) (int, *key) {
+	for i, k := range s.keys {
+		if k.name == name {
+			return i, k
+		}
+	}
+	return -1, nil
+}
+
+func (s *Server) addKey(name string, sk crypto.PrivKey) (*keyOutput, error) {
+	if name == "self" {
+		return nil, fmt.Errorf("cannot create key with name 'self'")
+	}
+	if _, k := s.findKey(name); k != nil {
+		return nil, fmt.Errorf("key with name '%s' already exists", name)
+	}
+	k := &key{name: name, sk: sk}
+	s.keys = append(s.keys, k)
+	return k.output()
+}
+
+func (s *Server) keyGen(req *request, res *response) error {
+	name, err := req.arg(0, "name")
+	if err != nil {
+		return err
+	}
+	size, err := req.intOption("size", -1)
+	if err != nil {
+		return err
+	}
+
+	var sk crypto.PrivKey
+	switch typ := req.stringOption("type", "ed25519"); typ {
+	case "rsa":
+		if size == -1 {
+			size = 2048
+		}
+		if size < 2048 {
+			return fmt.Errorf("rsa keys must be >= 2048 bits to be useful")
+		}
+		sk, _, err = crypto.GenerateKeyPair(crypto.RSA, int(size))
+	case "ed25519":
+		sk, _, err = crypto.GenerateEd25519Key(nil)
+	default:
+		return fmt.Errorf("unrecognized key type: %s", typ)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := s.addKey(name, sk)
+	if err != nil {
+		return err
+	}
+	return res.emit(out)
+}
+
+func (s *Server) keyImport(req *request, res *response) error {
+	name, err := req.arg(0, "name")
+	if err != nil {
+		return err
+	}
+	f, err := req.file()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	var sk crypto.PrivKey
+	switch format := req.stringOption("format", "libp2p-protobuf-cleartext"); format {
+	case "libp2p-protobuf-cleartext":
+		sk, err = crypto.UnmarshalPrivateKey(data)
+	case "pem-pkcs8-cleartext":
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != "PRIVATE KEY" {
+			return fmt.Errorf("failed to decode PEM block")
+		}
+		stdKey, perr := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if perr != nil {
+			return perr
+		}
+		if k, ok := stdKey.(ed25519.PrivateKey); ok {
+			stdKey = &k
+		}
+		sk, _, err = crypto.KeyPairFromStdKey(stdKey)
+	default:
+		return fmt.Errorf("unrecognized import format: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("the key cannot be imported: %w", err)
+	}
+
+	out, err := s.addKey(name, sk)
+	if err != nil {
+		return err
+	}
+	return res.emit(out)
+}
+
+func (s *Server) keyList(req *request, res *response) error {
+	var out struct{ Keys []*keyOutput }
+	for _, k := range s.keys {
+		ko, err := k.output()
+		if err != nil {
+			return err
+		}
+		out.Keys = append(out.Keys, ko)
+	}
+	return res.emit(out)
+}
+
+func (s *Server) keyRename(req *request, res *response) error {
+	oldName, err := req.arg(0, "oldName")
+	if err != nil {
+		return err
+	}
+	newName, err := req.arg(1, "newName")
+	if err != nil {
+		return err
+	}
+	force, err := req.boolOption("force", false)
+	if err != nil {
+		return err
+	}
+
+	if oldName == "self" {
+		return fmt.Errorf("cannot rename key with name 'self'")
+	}
+	if newName == "self" {
+		return fmt.Errorf("cannot overwrite key with name 'self'")
+	}
+	_, k := s.findKey(oldName)
+	if k == nil {
+		return fmt.Errorf("no key named %s was found", oldName)
+	}
+
+	overwrite := false
+	if i, existing := s.findKey(newName); existing != nil {
+		if !force {
+			return fmt.Errorf("key by that name already exists, refusing to overwrite")
+		}
+		s.keys = append(s.keys[:i], s.keys[i+1:]...)
+		overwrite = true
+	}
+	k.name = newName
+
+	id, err := k.id()
+	if err != nil {
+		return err
+	}
+	return res.emit(struct {
+		Was       string
+		Now       string
+		Id        string
+		Overwrite bool
+	}{oldName, newName, id, overwrite})
+}
+
+func (s *Server) keyRm(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "name")
+	}
+
+	var out struct{ Keys []*keyOutput }
+	for _, name := range req.args {
+		if name == "self" {
+			return fmt.Errorf("cannot remove key with name 'self'")
+		}
+		i, k := s.findKey(name)
+		if k == nil {
+			return fmt.Errorf("no key named %s was found", name)
+		}
+		ko, err := k.output()
+		if err != nil {
+			return err
+		}
+		s.keys = append(s.keys[:i], s.keys[i+1:]...)
+		out.Keys = append(out.Keys, ko)
+	}
+	return res.emit(out)
+}
+
+func (s *Server) namePublish(req *request, res *response) error {
+	p, err := req.arg(0, "ipfs-path")
+	if err != nil {
+		return err
+	}
+	resolve, err := req.boolOption("resolve", true)
+	if err != nil {
+		return err
+	}
+
+	_, k := s.findKey(req.stringOption("key", "self"))
+	if k == nil {
+		return fmt.Errorf("no key by the given name was found")
+	}
+	if !strings.HasPrefix(p, "/ipfs/") && !strings.HasPrefix(p, "/ipns/") {
+		p = "/ipfs/" + p
+	}
+	if resolve {
+		if _, err := s.resolve(req.Context(), p); err != nil {
+			return err
+		}
+	}
+
+	pid, err := k.peerID()
+	if err != nil {
+		return err
+	}
+	id, err := k.id()
+	if err != nil {
+		return err
+	}
+	s.names[pid] = p
+	return res.emit(map[string]string{"Name": id, "Value": p})
+}
+
+func (s *Server) nameResolve(req *request, res *response) error {
+	name := strings.TrimPrefix(req.stringOption("arg", ""), "/ipns/")
+
+	var pid peer.ID
+	if name == "" {
+		pid = s.PeerID()
+	} else {
+		var err error
+		if pid, err = peer.Decode(name); err != nil {
+			return fmt.Errorf("could not resolve name: %w", err)
+		}
+	}
+
+	p, ok := s.names[pid]
+	if !ok {
+		return fmt.Errorf("could not resolve name")
+	}
+	return res.emit(map[string]string{"Path": p})
+}
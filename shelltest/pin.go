@@ -0,0 +1,356 @@
+package shelltest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// walk calls f for every node of the DAG rooted at c, parents first.
+// Shared subgraphs are only visited once.
+func (s *Server) walk(ctx context.Context, c cid.Cid, f func(ipld.Node) error) error {
+	seen := cid.NewSet()
+	var visit func(c cid.Cid) error
+	visit = func(c cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		nd, err := s.dag.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := f(nd); err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := visit(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(c)
+}
+
+// pinned returns the pin type of c, computing indirect pins from the
+// recursive ones.
+func (s *Server) pinned(ctx context.Context, c cid.Cid) (string, error) {
+	if typ, ok := s.pins[c]; ok {
+		return typ, nil
+	}
+	indirect, err := s.indirectPins(ctx)
+	if err != nil {
+		return "", err
+	}
+	if indirect.Has(c) {
+		return "indirect", nil
+	}
+	return "", nil
+}
+
+func (s *Server) indirectPins(ctx context.Context) (*cid.Set, error) {
+	indirect := cid.NewSet()
+	for root, typ := range s.pins {
+		if typ != "recursive" {
+			continue
+		}
+		err := s.walk(ctx, root, func(nd ipld.Node) error {
+			if !nd.Cid().Equals(root) {
+				indirect.Add(nd.Cid())
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return indirect, nil
+}
+
+type addPinOutput struct {
+	Pins     []string `json:",omitempty"`
+	Progress int      `json:",omitempty"`
+}
+
+func (s *Server) pinAdd(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "ipfs-path")
+	}
+	recursive, err := req.boolOption("recursive", true)
+	if err != nil {
+		return err
+	}
+	progress, err := req.boolOption("progress", false)
+	if err != nil {
+		return err
+	}
+	name := req.stringOption("name", "")
+
+	// with progress, the number of nodes fetched is reported as the DAG is
+	// walked
+	fetched := 0
+	fetch := func(ipld.Node) error {
+		fetched++
+		if !progress {
+			return nil
+		}
+		return res.emit(addPinOutput{Progress: fetched})
+	}
+
+	var out addPinOutput
+	for _, p := range req.args {
+		nd, err := s.resolve(req.Context(), p)
+		if err != nil {
+			return err
+		}
+		c := nd.Cid()
+
+		if recursive {
+			// make sure the whole DAG is available
+			if err := s.walk(req.Context(), c, fetch); err != nil {
+				return fmt.Errorf("pin: %w", err)
+			}
+			s.pins[c] = "recursive"
+		} else {
+			if s.pins[c] == "recursive" {
+				return fmt.Errorf("pin: %s already pinned recursively", c)
+			}
+			s.pins[c] = "direct"
+		}
+		if name != "" {
+			s.pinNames[c] = name
+		}
+		out.Pins = append(out.Pins, c.String())
+	}
+	return res.emit(out)
+}
+
+func (s *Server) pinRm(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "ipfs-path")
+	}
+	recursive, err := req.boolOption("recursive", true)
+	if err != nil {
+		return err
+	}
+
+	var out struct{ Pins []string }
+	for _, p := range req.args {
+		nd, err := s.resolve(req.Context(), p)
+		if err != nil {
+			return err
+		}
+		c := nd.Cid()
+
+		switch s.pins[c] {
+		case "recursive":
+			if !recursive {
+				return fmt.Errorf("%s is pinned recursively", c)
+			}
+		case "direct":
+		default:
+			return fmt.Errorf("not pinned or pinned indirectly")
+		}
+		delete(s.pins, c)
+		delete(s.pinNames, c)
+		out.Pins = append(out.Pins, c.String())
+	}
+	return res.emit(out)
+}
+
+type pinInfo struct {
+	Type string
+	Name string `json:",omitempty"`
+}
+
+type pinStreamInfo struct {
+	Cid  string
+	Type string
+	Name string `json:",omitempty"`
+}
+
+func (s *Server) pinLs(req *request, res *response) error {
+	typ := req.stringOption("type", "all")
+	switch typ {
+	case "all", "direct", "indirect", "recursive":
+	default:
+		return clientError("invalid type '%s', must be one of {direct, indirect, recursive, all}", typ)
+	}
+	stream, err := req.boolOption("stream", false)
+	if err != nil {
+		return err
+	}
+	names, err := req.boolOption("names", false)
+	if err != nil {
+		return err
+	}
+	name := req.stringOption("name", "")
+	names = names || name != ""
+
+	keys := make(map[string]pinInfo)
+	if len(req.args) > 0 {
+		for _, p := range req.args {
+			nd, err := s.resolve(req.Context(), p)
+			if err != nil {
+				return err
+			}
+			pinType, err := s.pinned(req.Context(), nd.Cid())
+			if err != nil {
+				return err
+			}
+			if pinType == "" || (typ != "all" && typ != pinType) {
+				return fmt.Errorf("path '%s' is not pinned", p)
+			}
+			keys[nd.Cid().String()] = pinInfo{Type: pinType}
+		}
+	} else {
+		for c, pinType := range s.pins {
+			if typ == "all" || typ == pinType {
+				keys[c.String()] = pinInfo{Type: pinType}
+			}
+		}
+		if typ == "all" || typ == "indirect" {
+			indirect, err := s.indirectPins(req.Context())
+			if err != nil {
+				return err
+			}
+			err = indirect.ForEach(func(c cid.Cid) error {
+				if _, ok := s.pins[c]; !ok {
+					keys[c.String()] = pinInfo{Type: "indirect"}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if names {
+		for k, info := range keys {
+			c, _ := cid.Decode(k)
+			info.Name = s.pinNames[c]
+			if !strings.Contains(info.Name, name) {
+				delete(keys, k)
+				continue
+			}
+			keys[k] = info
+		}
+	}
+
+	if !stream {
+		return res.emit(struct{ Keys map[string]pinInfo }{keys})
+	}
+	for c, info := range keys {
+		if err := res.emit(pinStreamInfo{Cid: c, Type: info.Type, Name: info.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) pinUpdate(req *request, res *response) error {
+	from, err := req.arg(0, "from-path")
+	if err != nil {
+		return err
+	}
+	to, err := req.arg(1, "to-path")
+	if err != nil {
+		return err
+	}
+	unpin, err := req.boolOption("unpin", true)
+	if err != nil {
+		return err
+	}
+
+	fromNd, err := s.resolve(req.Context(), from)
+	if err != nil {
+		return err
+	}
+	toNd, err := s.resolve(req.Context(), to)
+	if err != nil {
+		return err
+	}
+	fromCid, toCid := fromNd.Cid(), toNd.Cid()
+
+	if s.pins[fromCid] != "recursive" {
+		return fmt.Errorf("'from' cid was not recursively pinned already")
+	}
+	if err := s.walk(req.Context(), toCid, func(ipld.Node) error { return nil }); err != nil {
+		return err
+	}
+	s.pins[toCid] = "recursive"
+	if name, ok := s.pinNames[fromCid]; ok {
+		s.pinNames[toCid] = name
+	}
+	if unpin && !fromCid.Equals(toCid) {
+		delete(s.pins, fromCid)
+		delete(s.pinNames, fromCid)
+	}
+	return res.emit(struct{ Pins []string }{[]string{fromCid.String(), toCid.String()}})
+}
+
+type badNode struct {
+	Cid string
+	Err string
+}
+
+type pinVerifyRes struct {
+	Cid      string    `json:",omitempty"`
+	Err      string    `json:",omitempty"`
+	Ok       bool      `json:",omitempty"`
+	BadNodes []badNode `json:",omitempty"`
+}
+
+func (s *Server) pinVerify(req *request, res *response) error {
+	verbose, err := req.boolOption("verbose", false)
+	if err != nil {
+		return err
+	}
+	quiet, err := req.boolOption("quiet", false)
+	if err != nil {
+		return err
+	}
+	if verbose && quiet {
+		return fmt.Errorf("the --verbose and --quiet options can not be used at the same time")
+	}
+
+	var roots []cid.Cid
+	for c, typ := range s.pins {
+		if typ == "recursive" {
+			roots = append(roots, c)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].KeyString() < roots[j].KeyString() })
+
+	for _, root := range roots {
+		out := pinVerifyRes{Cid: root.String(), Ok: true}
+		var check func(c cid.Cid)
+		check = func(c cid.Cid) {
+			nd, err := s.dag.Get(req.Context(), c)
+			if err != nil {
+				out.Ok = false
+				if !quiet {
+					out.BadNodes = append(out.BadNodes, badNode{Cid: c.String(), Err: err.Error()})
+				}
+				return
+			}
+			for _, l := range nd.Links() {
+				check(l.Cid)
+			}
+		}
+		check(root)
+
+		if !out.Ok || verbose {
+			if err := res.emit(out); err != nil {
+				return err
+			}
+		}
+	}
+	res.start("application/json", false)
+	return nil
+}
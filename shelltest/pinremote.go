@@ -0,0 +1,265 @@
+package shelltest
+
+import (
+	"fmt"
+	neturl "net/url"
+	gopath "path"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// remoteService is a remote pinning service. The fake doesn't talk to it;
+// pins are kept in memory instead. Pins added in the background stay queued,
+// other pins are pinned right away.
+type remoteService struct {
+	endpoint string
+	key      string
+	pins     []*remotePin
+}
+
+type remotePin struct {
+	Status string
+	Cid    string
+	Name   string
+}
+
+var remotePinStatuses = []string{"queued", "pinning", "pinned", "failed"}
+
+// remoteService returns the service named by the service option.
+func (s *Server) remoteService(req *request) (*remoteService, error) {
+	if !req.has("service") {
+		return nil, fmt.Errorf("a service name must be passed")
+	}
+	name := req.stringOption("service", "")
+	if name == "" {
+		return nil, fmt.Errorf("remote pinning service name not specified")
+	}
+	svc, ok := s.remote[name]
+	if !ok {
+		return nil, fmt.Errorf("service not known")
+	}
+	return svc, nil
+}
+
+// stringsOption returns the values of a comma-delimited string array
+// option.
+func (r *request) stringsOption(name string, def ...string) []string {
+	v, ok := r.options[name]
+	if !ok {
+		return def
+	}
+	var out []string
+	for _, s := range v {
+		out = append(out, strings.Split(s, ",")...)
+	}
+	return out
+}
+
+// remotePinFilter returns a function selecting the pins matching the name,
+// cid and status options.
+func remotePinFilter(req *request) (func(*remotePin) bool, error) {
+	name := req.stringOption("name", "")
+	cids := make(map[string]bool)
+	for _, raw := range req.stringsOption("cid") {
+		c, err := cid.Decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("CID %q cannot be parsed: %v", raw, err)
+		}
+		cids[c.String()] = true
+	}
+	statuses := make(map[string]bool)
+	for _, st := range req.stringsOption("status", "pinned") {
+		valid := false
+		for _, known := range remotePinStatuses {
+			valid = valid || st == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("status %q is not valid", st)
+		}
+		statuses[st] = true
+	}
+
+	return func(p *remotePin) bool {
+		return (name == "" || p.Name == name) &&
+			(len(cids) == 0 || cids[p.Cid]) &&
+			statuses[p.Status]
+	}, nil
+}
+
+func (s *Server) pinRemoteAdd(req *request, res *response) error {
+	svc, err := s.remoteService(req)
+	if err != nil {
+		return err
+	}
+	if len(req.args) != 1 {
+		return fmt.Errorf("expecting one CID argument")
+	}
+	background, err := req.boolOption("background", false)
+	if err != nil {
+		return err
+	}
+	nd, err := s.resolve(req.Context(), req.args[0])
+	if err != nil {
+		return err
+	}
+
+	pin := &remotePin{
+		Status: "pinned",
+		Cid:    nd.Cid().String(),
+		Name:   req.stringOption("name", ""),
+	}
+	if background {
+		pin.Status = "queued"
+	}
+	svc.pins = append(svc.pins, pin)
+	return res.emit(pin)
+}
+
+func (s *Server) pinRemoteLs(req *request, res *response) error {
+	svc, err := s.remoteService(req)
+	if err != nil {
+		return err
+	}
+	match, err := remotePinFilter(req)
+	if err != nil {
+		return err
+	}
+	for _, pin := range svc.pins {
+		if !match(pin) {
+			continue
+		}
+		if err := res.emit(pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) pinRemoteRm(req *request, res *response) error {
+	svc, err := s.remoteService(req)
+	if err != nil {
+		return err
+	}
+	if len(req.args) > 0 {
+		return fmt.Errorf("unexpected argument %q", req.args[0])
+	}
+	force, err := req.boolOption("force", false)
+	if err != nil {
+		return err
+	}
+	match, err := remotePinFilter(req)
+	if err != nil {
+		return err
+	}
+
+	var keep []*remotePin
+	for _, pin := range svc.pins {
+		if !match(pin) {
+			keep = append(keep, pin)
+		}
+	}
+	if len(svc.pins)-len(keep) > 1 && !force {
+		return fmt.Errorf("multiple remote pins are matching this query, add --force to confirm the bulk removal")
+	}
+	svc.pins = keep
+	return nil
+}
+
+// normalizeEndpoint checks a service endpoint like the daemon does.
+func normalizeEndpoint(endpoint string) (string, error) {
+	uri, err := neturl.ParseRequestURI(endpoint)
+	if err != nil || !(uri.Scheme == "http" || uri.Scheme == "https") {
+		return "", fmt.Errorf("service endpoint must be a valid HTTP URL")
+	}
+
+	uri.Path = gopath.Clean(uri.Path)
+	uri.Path = strings.TrimSuffix(uri.Path, ".")
+	uri.Path = strings.TrimSuffix(uri.Path, "/")
+
+	if uri.RawQuery != "" {
+		return "", fmt.Errorf("service endpoint should be provided without any query parameters")
+	}
+	if strings.HasSuffix(uri.Path, "/pins") {
+		return "", fmt.Errorf("service endpoint should be provided without the /pins suffix")
+	}
+	return uri.String(), nil
+}
+
+func (s *Server) pinRemoteServiceAdd(req *request, res *response) error {
+	if len(req.args) < 3 {
+		return fmt.Errorf("expecting three arguments: service name, endpoint and key")
+	}
+	name := req.args[0]
+	endpoint, err := normalizeEndpoint(req.args[1])
+	if err != nil {
+		return err
+	}
+	if _, ok := s.remote[name]; ok {
+		return fmt.Errorf("service already present")
+	}
+	s.remote[name] = &remoteService{endpoint: endpoint, key: req.args[2]}
+	return nil
+}
+
+func (s *Server) pinRemoteServiceRm(req *request, res *response) error {
+	if len(req.args) != 1 {
+		return fmt.Errorf("expecting one argument: name")
+	}
+	delete(s.remote, req.args[0])
+	return nil
+}
+
+type remotePinCount struct {
+	Queued  int
+	Pinning int
+	Pinned  int
+	Failed  int
+}
+
+type remotePinServiceStat struct {
+	Status   string
+	PinCount *remotePinCount `json:",omitempty"`
+}
+
+type remotePinServiceDetails struct {
+	Service     string
+	ApiEndpoint string
+	Stat        *remotePinServiceStat `json:",omitempty"`
+}
+
+func (s *Server) pinRemoteServiceLs(req *request, res *response) error {
+	stat, err := req.boolOption("stat", false)
+	if err != nil {
+		return err
+	}
+
+	out := struct{ RemoteServices []remotePinServiceDetails }{
+		RemoteServices: make([]remotePinServiceDetails, 0, len(s.remote)),
+	}
+	for name, svc := range s.remote {
+		details := remotePinServiceDetails{Service: name, ApiEndpoint: svc.endpoint}
+		if stat {
+			count := &remotePinCount{}
+			for _, pin := range svc.pins {
+				switch pin.Status {
+				case "queued":
+					count.Queued++
+				case "pinning":
+					count.Pinning++
+				case "pinned":
+					count.Pinned++
+				case "failed":
+					count.Failed++
+				}
+			}
+			details.Stat = &remotePinServiceStat{Status: "valid", PinCount: count}
+		}
+		out.RemoteServices = append(out.RemoteServices, details)
+	}
+	sort.Slice(out.RemoteServices, func(i, j int) bool {
+		return out.RemoteServices[i].Service < out.RemoteServices[j].Service
+	})
+	return res.emit(out)
+}
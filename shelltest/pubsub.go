@@ -0,0 +1,173 @@
+package shelltest
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+
+	mbase "github.com/multiformats/go-multibase"
+)
+
+// pubsubMessage is a message as sent over the RPC API, with all binary
+// fields wrapped in multibase.
+type pubsubMessage struct {
+	From     string   `json:"from,omitempty"`
+	Data     string   `json:"data,omitempty"`
+	Seqno    string   `json:"seqno,omitempty"`
+	TopicIDs []string `json:"topicIDs,omitempty"`
+}
+
+type pubsub struct {
+	mu     sync.Mutex
+	seqno  uint64
+	subs   map[string]map[chan *pubsubMessage]struct{}
+	closed chan struct{}
+}
+
+func newPubsub() *pubsub {
+	return &pubsub{
+		subs:   make(map[string]map[chan *pubsubMessage]struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+func (p *pubsub) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.closed:
+	default:
+		close(p.closed)
+	}
+}
+
+func (p *pubsub) subscribe(topic string) chan *pubsubMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ch := make(chan *pubsubMessage, 32)
+	if p.subs[topic] == nil {
+		p.subs[topic] = make(map[chan *pubsubMessage]struct{})
+	}
+	p.subs[topic][ch] = struct{}{}
+	return ch
+}
+
+func (p *pubsub) unsubscribe(topic string, ch chan *pubsubMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.subs[topic], ch)
+	if len(p.subs[topic]) == 0 {
+		delete(p.subs, topic)
+	}
+}
+
+func (p *pubsub) publish(from, topic string, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seqno++
+	seqno := make([]byte, 8)
+	binary.BigEndian.PutUint64(seqno, p.seqno)
+
+	msg := &pubsubMessage{
+		From:     from,
+		Data:     encodeMultibase(data),
+		Seqno:    encodeMultibase(seqno),
+		TopicIDs: []string{encodeMultibase([]byte(topic))},
+	}
+	for ch := range p.subs[topic] {
+		select {
+		case ch <- msg:
+		default:
+			// slow subscriber, drop the message like the daemon would
+		}
+	}
+}
+
+func (p *pubsub) topics() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	topics := make([]string, 0, len(p.subs))
+	for t := range p.subs {
+		topics = append(topics, encodeMultibase([]byte(t)))
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func encodeMultibase(data []byte) string {
+	s, _ := mbase.Encode(mbase.Base64url, data)
+	return s
+}
+
+func topicArg(req *request) (string, error) {
+	arg, err := req.arg(0, "topic")
+	if err != nil {
+		return "", err
+	}
+	_, topic, err := mbase.Decode(arg)
+	if err != nil {
+		return "", clientError("URL arg must be multibase encoded: %s", err)
+	}
+	return string(topic), nil
+}
+
+func (s *Server) pubsubLs(req *request, res *response) error {
+	return res.emit(struct{ Strings []string }{s.pubsub.topics()})
+}
+
+func (s *Server) pubsubPeers(req *request, res *response) error {
+	if len(req.args) > 0 {
+		if _, err := topicArg(req); err != nil {
+			return err
+		}
+	}
+	// the fake node is never connected to any peers
+	return res.emit(struct{ Strings []string }{[]string{}})
+}
+
+func (s *Server) pubsubPub(req *request, res *response) error {
+	topic, err := topicArg(req)
+	if err != nil {
+		return err
+	}
+	f, err := req.file()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	s.pubsub.publish(s.PeerID().String(), topic, data)
+	return nil
+}
+
+func (s *Server) pubsubSub(req *request, res *response) error {
+	topic, err := topicArg(req)
+	if err != nil {
+		return err
+	}
+
+	ch := s.pubsub.subscribe(topic)
+	defer s.pubsub.unsubscribe(topic, ch)
+
+	// send the headers right away so the client knows it is subscribed
+	res.start("application/json", false)
+	res.flush()
+
+	for {
+		select {
+		case msg := <-ch:
+			if err := res.emit(msg); err != nil {
+				return err
+			}
+		case <-req.Context().Done():
+			return nil
+		case <-s.pubsub.closed:
+			return nil
+		}
+	}
+}
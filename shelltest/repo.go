@@ -0,0 +1,177 @@
+package shelltest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// storageMax is the default Datastore.StorageMax of the daemon, 10GB.
+const storageMax = 10_000_000_000
+
+type gcResult struct {
+	Key   cid.Cid
+	Error string `json:",omitempty"`
+}
+
+// gcRoots returns the blocks kept by the GC: the pinned DAGs and the MFS
+// tree. Like the keys of the blockstore, they are raw CIDs.
+func (s *Server) gcRoots(req *request) (*cid.Set, error) {
+	keep := cid.NewSet()
+	add := func(nd ipld.Node) error {
+		keep.Add(cid.NewCidV1(cid.Raw, nd.Cid().Hash()))
+		return nil
+	}
+
+	root, err := mfs.FlushPath(req.Context(), s.files, "/")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.walk(req.Context(), root.Cid(), add); err != nil {
+		return nil, err
+	}
+	for c, typ := range s.pins {
+		if typ == "direct" {
+			keep.Add(cid.NewCidV1(cid.Raw, c.Hash()))
+			continue
+		}
+		if err := s.walk(req.Context(), c, add); err != nil {
+			return nil, err
+		}
+	}
+	return keep, nil
+}
+
+func (s *Server) repoGc(req *request, res *response) error {
+	streamErrors, err := req.boolOption("stream-errors", false)
+	if err != nil {
+		return err
+	}
+	silent, err := req.boolOption("silent", false)
+	if err != nil {
+		return err
+	}
+
+	keep, err := s.gcRoots(req)
+	if err != nil {
+		return err
+	}
+	keys, err := s.bstore.AllKeysChan(req.Context())
+	if err != nil {
+		return err
+	}
+
+	var remove []cid.Cid
+	for c := range keys {
+		if !keep.Has(c) {
+			remove = append(remove, c)
+		}
+	}
+
+	errs := false
+	for _, c := range remove {
+		if err := s.bstore.DeleteBlock(req.Context(), c); err != nil {
+			if !streamErrors {
+				return err
+			}
+			errs = true
+			if err := res.emit(gcResult{Error: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if silent && !streamErrors {
+			continue
+		}
+		if err := res.emit(gcResult{Key: c}); err != nil {
+			return err
+		}
+	}
+	if errs {
+		return errors.New("encountered errors during gc run")
+	}
+	return nil
+}
+
+type repoStat struct {
+	RepoSize   uint64
+	StorageMax uint64
+	NumObjects uint64 `json:",omitempty"`
+	RepoPath   string `json:",omitempty"`
+	Version    string `json:",omitempty"`
+}
+
+func (s *Server) repoStat(req *request, res *response) error {
+	sizeOnly, err := req.boolOption("size-only", false)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.bstore.AllKeysChan(req.Context())
+	if err != nil {
+		return err
+	}
+	out := repoStat{StorageMax: storageMax}
+	for c := range keys {
+		size, err := s.bstore.GetSize(req.Context(), c)
+		if err != nil {
+			return err
+		}
+		out.RepoSize += uint64(size)
+		out.NumObjects++
+	}
+
+	if sizeOnly {
+		out.NumObjects = 0
+	} else {
+		out.RepoPath = "/shelltest"
+		out.Version = "fs-repo@" + repoVersion
+	}
+	return res.emit(out)
+}
+
+type verifyProgress struct {
+	Msg      string
+	Progress int
+}
+
+func (s *Server) repoVerify(req *request, res *response) error {
+	keys, err := s.bstore.AllKeysChan(req.Context())
+	if err != nil {
+		return err
+	}
+
+	var processed, fails int
+	for c := range keys {
+		blk, err := s.bstore.Get(req.Context(), c)
+		if err == nil {
+			var sum cid.Cid
+			if sum, err = c.Prefix().Sum(blk.RawData()); err == nil && !sum.Equals(c) {
+				err = errors.New("block in storage has different hash than requested")
+			}
+		}
+		if err != nil {
+			fails++
+			msg := fmt.Sprintf("block %s was corrupt (%s)", c, err)
+			if err := res.emit(verifyProgress{Msg: msg}); err != nil {
+				return err
+			}
+		}
+		processed++
+		if err := res.emit(verifyProgress{Progress: processed}); err != nil {
+			return err
+		}
+	}
+
+	if fails != 0 {
+		return errors.New("verify complete, some blocks were corrupt")
+	}
+	return res.emit(verifyProgress{Msg: "verify complete, all blocks validated."})
+}
+
+func (s *Server) repoVersion(req *request, res *response) error {
+	return res.emit(struct{ Version string }{repoVersion})
+}
@@ -0,0 +1,508 @@
+// Package shelltest provides an in-process fake of the Kubo RPC API, backed by
+// an in-memory blockstore, so that code built on top of shell.Shell can be
+// tested without a running daemon:
+//
+//	fake := shelltest.NewServer()
+//	defer fake.Close()
+//
+//	sh := shell.NewShell(fake.URL())
+package shelltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	gopath "path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	files "github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Version is the daemon version reported by the fake server.
+const Version = "0.22.0"
+
+// repoVersion is the repo format version of the daemon.
+const repoVersion = "14"
+
+// Error codes used by the Kubo RPC API in error responses.
+const (
+	errNormal = 0
+	errClient = 1
+)
+
+// cmdError is the error value sent by the Kubo RPC API.
+type cmdError struct {
+	Message string
+	Code    int
+	Type    string
+}
+
+func (e *cmdError) Error() string {
+	return e.Message
+}
+
+func clientError(format string, a ...interface{}) error {
+	return &cmdError{Message: fmt.Sprintf(format, a...), Code: errClient}
+}
+
+type command func(req *request, res *response) error
+
+// Server is a fake Kubo RPC server. It keeps all of its state in memory and
+// serves the /api/v0 endpoints most commonly used through shell.Shell.
+type Server struct {
+	srv      *httptest.Server
+	commands map[string]command
+
+	mu     sync.Mutex
+	bstore blockstore.Blockstore
+	dag    ipld.DAGService
+	pins   map[cid.Cid]string
+	// pinNames are the names given to pins, if any.
+	pinNames map[cid.Cid]string
+	remote   map[string]*remoteService
+	config   map[string]interface{}
+	files    *mfs.Root
+	self     crypto.PrivKey
+	keys     []*key
+	names    map[peer.ID]string
+	pubsub   *pubsub
+}
+
+// NewServer starts and returns a new fake server. The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.srv.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new fake server but doesn't start it. This is
+// useful to serve the fake through another listener, in which case Server
+// can be used as an http.Handler.
+func NewUnstartedServer() *Server {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := dag.NewDAGService(blockservice.New(bstore, offline.Exchange(bstore)))
+
+	noPublish := func(context.Context, cid.Cid) error { return nil }
+	root, err := mfs.NewRoot(context.Background(), dserv, ft.EmptyDirNode(), noPublish)
+	if err != nil {
+		panic(fmt.Sprintf("shelltest: could not create files root: %s", err))
+	}
+
+	self, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		panic(fmt.Sprintf("shelltest: could not generate identity: %s", err))
+	}
+
+	pid, err := peer.IDFromPrivateKey(self)
+	if err != nil {
+		panic(fmt.Sprintf("shelltest: could not derive peer ID: %s", err))
+	}
+
+	s := &Server{
+		bstore:   bstore,
+		dag:      dserv,
+		pins:     make(map[cid.Cid]string),
+		pinNames: make(map[cid.Cid]string),
+		remote:   make(map[string]*remoteService),
+		config:   newConfig(pid.String()),
+		files:    root,
+		self:     self,
+		keys:     []*key{{name: "self", sk: self}},
+		names:    make(map[peer.ID]string),
+		pubsub:   newPubsub(),
+	}
+
+	s.commands = map[string]command{
+		"version": s.locked(s.version),
+		"id":      s.locked(s.id),
+
+		"add": s.locked(s.add),
+		"cat": s.locked(s.cat),
+		"ls":  s.locked(s.ls),
+
+		"config":               s.locked(s.configCmd),
+		"config/show":          s.locked(s.configShow),
+		"config/replace":       s.locked(s.configReplace),
+		"config/profile/apply": s.locked(s.configProfileApply),
+
+		"pin/add":    s.locked(s.pinAdd),
+		"pin/rm":     s.locked(s.pinRm),
+		"pin/ls":     s.locked(s.pinLs),
+		"pin/update": s.locked(s.pinUpdate),
+		"pin/verify": s.locked(s.pinVerify),
+
+		"pin/remote/add":         s.locked(s.pinRemoteAdd),
+		"pin/remote/ls":          s.locked(s.pinRemoteLs),
+		"pin/remote/rm":          s.locked(s.pinRemoteRm),
+		"pin/remote/service/add": s.locked(s.pinRemoteServiceAdd),
+		"pin/remote/service/ls":  s.locked(s.pinRemoteServiceLs),
+		"pin/remote/service/rm":  s.locked(s.pinRemoteServiceRm),
+
+		"files/chcid": s.locked(s.filesChcid),
+		"files/cp":    s.locked(s.filesCp),
+		"files/flush": s.locked(s.filesFlush),
+		"files/ls":    s.locked(s.filesLs),
+		"files/mkdir": s.locked(s.filesMkdir),
+		"files/mv":    s.locked(s.filesMv),
+		"files/read":  s.locked(s.filesRead),
+		"files/rm":    s.locked(s.filesRm),
+		"files/stat":  s.locked(s.filesStat),
+		"files/write": s.locked(s.filesWrite),
+
+		"key/gen":    s.locked(s.keyGen),
+		"key/import": s.locked(s.keyImport),
+		"key/list":   s.locked(s.keyList),
+		"key/rename": s.locked(s.keyRename),
+		"key/rm":     s.locked(s.keyRm),
+
+		"name/publish": s.locked(s.namePublish),
+		"name/resolve": s.locked(s.nameResolve),
+
+		"dag/export":  s.locked(s.dagExport),
+		"dag/get":     s.locked(s.dagGet),
+		"dag/import":  s.locked(s.dagImport),
+		"dag/put":     s.locked(s.dagPut),
+		"dag/resolve": s.locked(s.dagResolve),
+		"dag/stat":    s.locked(s.dagStat),
+
+		"block/get":  s.locked(s.blockGet),
+		"block/put":  s.locked(s.blockPut),
+		"block/rm":   s.locked(s.blockRm),
+		"block/stat": s.locked(s.blockStat),
+
+		"repo/gc":      s.locked(s.repoGc),
+		"repo/stat":    s.locked(s.repoStat),
+		"repo/verify":  s.locked(s.repoVerify),
+		"repo/version": s.repoVersion,
+
+		"pubsub/ls":    s.pubsubLs,
+		"pubsub/peers": s.pubsubPeers,
+		"pubsub/pub":   s.pubsubPub,
+		"pubsub/sub":   s.pubsubSub,
+	}
+
+	s.srv = httptest.NewUnstartedServer(s)
+	return s
+}
+
+// URL returns the address of the server, suitable for shell.NewShell.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts down the server and blocks until all outstanding requests on
+// it have completed.
+func (s *Server) Close() {
+	s.pubsub.close()
+	s.srv.Close()
+}
+
+// PeerID returns the identity of the fake node.
+func (s *Server) PeerID() peer.ID {
+	id, _ := peer.IDFromPrivateKey(s.self)
+	return id
+}
+
+func (s *Server) locked(cmd command) command {
+	return func(req *request, res *response) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return cmd(req, res)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p := gopath.Clean(r.URL.Path); p != r.URL.Path {
+		// like the daemon's mux, redirect to the canonical path
+		u := *r.URL
+		u.Path = p
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, "/api/v0/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	cmd, ok := s.commands[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 - Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &request{Request: r, options: r.URL.Query()}
+	req.args = req.options["arg"]
+
+	res := &response{w: w}
+	if err := cmd(req, res); err != nil {
+		res.fail(err)
+	}
+}
+
+type request struct {
+	*http.Request
+	args    []string
+	options map[string][]string
+}
+
+// arg returns the i-th argument, failing with a client error if it is
+// missing.
+func (r *request) arg(i int, name string) (string, error) {
+	if i >= len(r.args) {
+		return "", clientError("argument %q is required", name)
+	}
+	return r.args[i], nil
+}
+
+func (r *request) has(name string) bool {
+	_, ok := r.options[name]
+	return ok
+}
+
+func (r *request) stringOption(name, def string) string {
+	if v, ok := r.options[name]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+func (r *request) boolOption(name string, def bool) (bool, error) {
+	v, ok := r.options[name]
+	if !ok || len(v) == 0 {
+		return def, nil
+	}
+	if v[0] == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v[0])
+	if err != nil {
+		return false, clientError("could not convert value %q to type bool (for option %q)", v[0], name)
+	}
+	return b, nil
+}
+
+func (r *request) intOption(name string, def int64) (int64, error) {
+	v, ok := r.options[name]
+	if !ok || len(v) == 0 {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v[0], 10, 64)
+	if err != nil {
+		return 0, clientError("could not convert value %q to type int (for option %q)", v[0], name)
+	}
+	return i, nil
+}
+
+// files returns the multipart body of the request as a directory.
+func (r *request) files() (files.Directory, error) {
+	mediatype, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediatype != "multipart/form-data" {
+		return nil, clientError("file argument was not provided")
+	}
+	mpr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return files.NewFileFromPartReader(mpr, mediatype)
+}
+
+// file returns the first regular file of the multipart body.
+func (r *request) file() (files.File, error) {
+	dir, err := r.files()
+	if err != nil {
+		return nil, err
+	}
+	it := dir.Entries()
+	if !it.Next() {
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+		return nil, clientError("file argument was not provided")
+	}
+	f, ok := it.Node().(files.File)
+	if !ok {
+		return nil, clientError("expected a regular file")
+	}
+	return f, nil
+}
+
+type response struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (r *response) start(contentType string, stream bool) {
+	if r.started {
+		return
+	}
+	r.started = true
+
+	h := r.w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Trailer", "X-Stream-Error")
+	if stream {
+		h.Set("X-Stream-Output", "1")
+	} else {
+		h.Set("X-Chunked-Output", "1")
+	}
+	r.w.WriteHeader(http.StatusOK)
+}
+
+func (r *response) flush() {
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// emit writes a single JSON value to the response.
+func (r *response) emit(v interface{}) error {
+	r.start("application/json", false)
+	if err := json.NewEncoder(r.w).Encode(v); err != nil {
+		return err
+	}
+	r.flush()
+	return nil
+}
+
+// stream copies raw output to the response.
+func (r *response) stream(rd io.Reader) error {
+	r.start("text/plain", true)
+	_, err := io.Copy(r.w, rd)
+	return err
+}
+
+// fail reports err, either as an error response or, if output has already
+// been sent, as a stream error trailer.
+func (r *response) fail(err error) {
+	if r.started {
+		r.w.Header().Set("X-Stream-Error", err.Error())
+		return
+	}
+
+	e := &cmdError{Message: err.Error(), Code: errNormal}
+	errors.As(err, &e)
+	e.Type = "error"
+
+	status := http.StatusInternalServerError
+	if e.Code == errClient {
+		status = http.StatusBadRequest
+	}
+	r.w.Header().Set("Content-Type", "application/json")
+	r.w.WriteHeader(status)
+	json.NewEncoder(r.w).Encode(e)
+}
+
+func (s *Server) version(req *request, res *response) error {
+	return res.emit(map[string]string{
+		"Version": Version,
+		"Commit":  "shelltest",
+		"Repo":    repoVersion,
+		"System":  "fake",
+		"Golang":  "",
+	})
+}
+
+func (s *Server) id(req *request, res *response) error {
+	sk := s.self
+	if len(req.args) > 0 {
+		return fmt.Errorf("peer %s not found: routing not supported", req.args[0])
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	pk, err := crypto.MarshalPublicKey(sk.GetPublic())
+	if err != nil {
+		return err
+	}
+	return res.emit(map[string]interface{}{
+		"ID":              id.String(),
+		"PublicKey":       pk,
+		"Addresses":       []string{},
+		"AgentVersion":    "kubo/" + Version + "/shelltest",
+		"ProtocolVersion": "ipfs/0.1.0",
+	})
+}
+
+// resolve resolves an /ipfs/ or /ipns/ path, or a bare CID with an optional
+// path, to an IPLD node. Path segments are resolved through UnixFS
+// directories.
+func (s *Server) resolve(ctx context.Context, p string) (ipld.Node, error) {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	switch segments[0] {
+	case "ipfs":
+		segments = segments[1:]
+	case "ipns":
+		if len(segments) < 2 {
+			return nil, fmt.Errorf("invalid path %q", p)
+		}
+		id, err := peer.Decode(segments[1])
+		if err != nil {
+			return nil, err
+		}
+		target, ok := s.names[id]
+		if !ok {
+			return nil, fmt.Errorf("could not resolve name")
+		}
+		return s.resolve(ctx, target+"/"+strings.Join(segments[2:], "/"))
+	}
+	if len(segments) == 0 || segments[0] == "" {
+		return nil, fmt.Errorf("invalid path %q", p)
+	}
+
+	c, err := cid.Decode(segments[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", p, err)
+	}
+	nd, err := s.dag.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range segments[1:] {
+		if name == "" {
+			continue
+		}
+		dir, err := uio.NewDirectoryFromNode(s.dag, nd)
+		if err != nil {
+			return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+		}
+		child, err := dir.Find(ctx, name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+			}
+			return nil, err
+		}
+		nd = child
+	}
+	return nd, nil
+}
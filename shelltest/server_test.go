@@ -0,0 +1,212 @@
+package shelltest_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/cheekybits/is"
+
+	shell "github.com/ipfs/go-ipfs-api"
+	"github.com/ipfs/go-ipfs-api/options"
+	"github.com/ipfs/go-ipfs-api/shelltest"
+)
+
+func newShell(t *testing.T) *shell.Shell {
+	fake := shelltest.NewServer()
+	t.Cleanup(fake.Close)
+	return shell.NewShell(fake.URL())
+}
+
+func TestAddCat(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	mhash, err := s.Add(bytes.NewBufferString("Hello IPFS Shell tests"))
+	is.Nil(err)
+	is.Equal(mhash, "QmUfZ9rAdhV5ioBzXKdUTh2ZNsz9bzbkaLVyQ8uc8pj21F")
+
+	mhash, err = s.Add(bytes.NewBufferString("Hello IPFS Shell tests"), shell.CidVersion(1))
+	is.Nil(err)
+	is.Equal(mhash, "bafkreia5cxdsptovvt7qykfcrg4xlpaerart45pfn5di4rbivunybstmii")
+
+	rc, err := s.Cat(mhash)
+	is.Nil(err)
+	data, err := io.ReadAll(rc)
+	is.Nil(err)
+	is.Equal(string(data), "Hello IPFS Shell tests")
+
+	_, err = s.Cat("QmUfZ9rAdhV5ioBzXKdUTh2ZNsz9bzbkaLVyQ8uc8pj21F/missing")
+	is.Err(err)
+}
+
+func TestAddDirList(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	cid, err := s.AddDir("../testdata")
+	is.Nil(err)
+	is.Equal(cid, "QmS4ustL54uo8FzR9455qaxZwuMiUhyvMcX9Ba8nUH4uVv")
+
+	list, err := s.List(cid)
+	is.Nil(err)
+	is.Equal(len(list), 7)
+	is.Equal(list[0].Name, "about")
+	is.Equal(list[0].Hash, "QmZTR5bcpQD7cFgTorqxZDYaew1Wqgfbd2ud9QqGPAkK2V")
+	is.Equal(list[0].Size, 1677)
+	is.Equal(list[0].Type, shell.TFile)
+}
+
+func TestPins(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	h, err := s.Add(bytes.NewBufferString("shelltest pins"), shell.Pin(false))
+	is.Nil(err)
+
+	pins, err := s.Pins()
+	is.Nil(err)
+	_, ok := pins[h]
+	is.False(ok)
+
+	is.Nil(s.Pin(h))
+	pins, err = s.PinsOfType(context.Background(), shell.RecursivePin)
+	is.Nil(err)
+	is.Equal(pins[h].Type, shell.RecursivePin)
+
+	is.Nil(s.Unpin(h))
+	is.Err(s.Unpin(h))
+}
+
+func TestFiles(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+	ctx := context.Background()
+
+	for _, f := range []string{"about", "readme"} {
+		file, err := os.Open("../testdata/" + f)
+		is.Nil(err)
+		err = s.FilesWrite(ctx, "/testdata/"+f, file, shell.FilesWrite.Parents(true), shell.FilesWrite.Create(true))
+		file.Close()
+		is.Nil(err)
+	}
+
+	stat, err := s.FilesStat(ctx, "/testdata")
+	is.Nil(err)
+	is.Equal(stat.Hash, "QmfZtacPc5nch976ZsiBw6nhLmTzy5JjW2pzZg8j7GjqWq")
+	is.Equal(stat.Type, "directory")
+
+	is.Nil(s.FilesMv(ctx, "/testdata/readme", "/testdata/readme2"))
+	list, err := s.FilesLs(ctx, "/testdata", shell.FilesLs.Stat(true))
+	is.Nil(err)
+	is.Equal(len(list), 2)
+	is.Equal(list[1].Name, "readme2")
+	is.Equal(list[1].Hash, "QmfZt7xPekp7npSM6DHDUnFseAiNZQs7wq6muH9o99RsCB")
+
+	reader, err := s.FilesRead(ctx, "/testdata/readme2", shell.FilesRead.Count(5))
+	is.Nil(err)
+	data, err := io.ReadAll(reader)
+	is.Nil(err)
+	is.Equal(string(data), "Hello")
+
+	is.Err(s.FilesRm(ctx, "/testdata", false))
+	is.Nil(s.FilesRm(ctx, "/testdata", true))
+	_, err = s.FilesStat(ctx, "/testdata")
+	is.Err(err)
+}
+
+func TestKeysAndNames(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+	ctx := context.Background()
+
+	key, err := s.KeyGen(ctx, "testKey")
+	is.Nil(err)
+	is.Equal(key.Name, "testKey")
+
+	_, err = s.KeyGen(ctx, "testKey")
+	is.Err(err)
+
+	keys, err := s.KeyList(ctx)
+	is.Nil(err)
+	is.Equal(len(keys), 2)
+	is.Equal(keys[0].Name, "self")
+
+	h, err := s.Add(bytes.NewBufferString("shelltest names"))
+	is.Nil(err)
+	resp, err := s.PublishWithDetails(h, "testKey", 0, 0, true)
+	is.Nil(err)
+	is.Equal(resp.Name, key.Id)
+	is.Equal(resp.Value, "/ipfs/"+h)
+
+	p, err := s.Resolve(key.Id)
+	is.Nil(err)
+	is.Equal(p, "/ipfs/"+h)
+
+	_, err = s.KeyRm(ctx, "testKey")
+	is.Nil(err)
+	_, err = s.KeyRm(ctx, "testKey")
+	is.NotNil(err)
+	is.Equal(err.Error(), "key/rm: no key named testKey was found")
+}
+
+func TestDag(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	c, err := s.DagPut(`{"x": "abc","y":"def"}`, "dag-json", "dag-cbor")
+	is.Nil(err)
+	is.Equal(c, "bafyreidrm3r2k6vlxqp2fk47sboeycf7apddib47w7cyagrajtpaxxl2pi")
+
+	var out map[string]string
+	is.Nil(s.DagGet(c, &out))
+	is.Equal(out["x"], "abc")
+
+	var y string
+	is.Nil(s.DagGet(c+"/y", &y))
+	is.Equal(y, "def")
+
+	carFile, err := os.ReadFile("../tests/test.car")
+	is.Nil(err)
+	imported, err := s.DagImportWithOpts(carFile, options.Dag.Stats(true))
+	is.Nil(err)
+	is.Equal(imported.Roots[0].Root.Cid.Value, "bafybeibnhml2ecayjfa747ryfuy3ws5im6q4kscapqv7ajaspezwsw63ee")
+	is.Equal(imported.Stats.BlockBytesCount, 173)
+	is.Equal(imported.Stats.BlockCount, 5)
+}
+
+func TestBlock(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	c, err := s.BlockPut([]byte("shelltest block"), "", "sha2-256", -1)
+	is.Nil(err)
+
+	key, size, err := s.BlockStat(c)
+	is.Nil(err)
+	is.Equal(key, c)
+	is.Equal(size, len("shelltest block"))
+
+	data, err := s.BlockGet(c)
+	is.Nil(err)
+	is.Equal(string(data), "shelltest block")
+}
+
+func TestPubSub(t *testing.T) {
+	is := is.New(t)
+	s := newShell(t)
+
+	topic := "test\n topic"
+	sub, err := s.PubSubSubscribe(topic)
+	is.Nil(err)
+	defer sub.Cancel()
+
+	is.Nil(s.PubSubPublish(topic, "Hello\r\nWorld"))
+
+	msg, err := sub.Next()
+	is.Nil(err)
+	is.Equal(string(msg.Data), "Hello\r\nWorld")
+	is.Equal(msg.TopicIDs, []string{topic})
+}
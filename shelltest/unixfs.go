@@ -0,0 +1,496 @@
+package shelltest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	gopath "path"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	files "github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	unixfs_pb "github.com/ipfs/boxo/ipld/unixfs/pb"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// how many bytes of progress to wait before sending a progress event.
+const progressIncrement = 1024 * 256
+
+type addEvent struct {
+	Name  string
+	Hash  string `json:",omitempty"`
+	Bytes int64  `json:",omitempty"`
+	Size  string `json:",omitempty"`
+}
+
+// cidPrefix builds the CID prefix selected by the cid-version and hash
+// options. A non-default hash function implies CIDv1. It returns nil if
+// neither option is set.
+func cidPrefix(req *request) (*cid.Prefix, error) {
+	cidVer, err := req.intOption("cid-version", 0)
+	if err != nil {
+		return nil, err
+	}
+	hashFun := strings.ToLower(req.stringOption("hash", "sha2-256"))
+	if !req.has("cid-version") && !req.has("hash") {
+		return nil, nil
+	}
+	if !req.has("cid-version") && hashFun != "sha2-256" {
+		cidVer = 1
+	}
+
+	prefix, err := dag.PrefixForCidVersion(int(cidVer))
+	if err != nil {
+		return nil, err
+	}
+
+	code, ok := mh.Names[hashFun]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized hash function: %s", hashFun)
+	}
+	if prefix.Version == 0 && code != mh.SHA2_256 {
+		return nil, fmt.Errorf("CIDv0 only supports sha2-256")
+	}
+	prefix.MhType = code
+	prefix.MhLength = -1
+	return &prefix, nil
+}
+
+// inlineBuilder builds identity CIDs for blocks up to limit bytes.
+type inlineBuilder struct {
+	cid.Builder
+	limit int
+}
+
+func (b inlineBuilder) Sum(data []byte) (cid.Cid, error) {
+	if len(data) > b.limit {
+		return b.Builder.Sum(data)
+	}
+	return cid.V1Builder{Codec: b.GetCodec(), MhType: mh.IDENTITY}.Sum(data)
+}
+
+func (b inlineBuilder) WithCodec(c uint64) cid.Builder {
+	return inlineBuilder{b.Builder.WithCodec(c), b.limit}
+}
+
+type adder struct {
+	ctx       context.Context
+	dag       ipld.DAGService
+	res       *response
+	prefix    cid.Builder
+	chunker   string
+	rawLeaves bool
+	trickle   bool
+	progress  bool
+	dirs      []addEvent
+}
+
+func (s *Server) add(req *request, res *response) error {
+	onlyHash, err := req.boolOption("only-hash", false)
+	if err != nil {
+		return err
+	}
+	pin, err := req.boolOption("pin", true)
+	if err != nil {
+		return err
+	}
+	progress, err := req.boolOption("progress", false)
+	if err != nil {
+		return err
+	}
+	trickle, err := req.boolOption("trickle", false)
+	if err != nil {
+		return err
+	}
+	prefix, err := cidPrefix(req)
+	if err != nil {
+		return err
+	}
+	if prefix == nil {
+		p, _ := dag.PrefixForCidVersion(0)
+		prefix = &p
+	}
+	rawLeaves, err := req.boolOption("raw-leaves", prefix.Version > 0)
+	if err != nil {
+		return err
+	}
+	nocopy, err := req.boolOption("nocopy", false)
+	if err != nil {
+		return err
+	}
+	if nocopy {
+		if req.has("raw-leaves") && !rawLeaves {
+			return fmt.Errorf("nocopy option requires '--raw-leaves' to be enabled as well")
+		}
+		return fmt.Errorf("either the filestore or the urlstore must be enabled to use nocopy, see: https://git.io/vNItf")
+	}
+	wrap, err := req.boolOption("wrap-with-directory", false)
+	if err != nil {
+		return err
+	}
+	inline, err := req.boolOption("inline", false)
+	if err != nil {
+		return err
+	}
+	inlineLimit, err := req.intOption("inline-limit", 32)
+	if err != nil {
+		return err
+	}
+	toFiles, toFilesSet := req.stringOption("to-files", ""), req.has("to-files")
+	if toFilesSet && onlyHash {
+		return clientError("only-hash and to-files options are not compatible")
+	}
+
+	dir, err := req.files()
+	if err != nil {
+		return err
+	}
+
+	dserv := s.dag
+	if onlyHash {
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+		dserv = dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	}
+
+	var builder cid.Builder = *prefix
+	if inline {
+		builder = inlineBuilder{builder, int(inlineLimit)}
+	}
+
+	a := &adder{
+		ctx:       req.Context(),
+		dag:       dserv,
+		res:       res,
+		prefix:    builder,
+		chunker:   req.stringOption("chunker", ""),
+		rawLeaves: rawLeaves,
+		trickle:   trickle,
+		progress:  progress,
+	}
+
+	var root ipld.Node
+	var added []*namedNode
+	if wrap {
+		if root, err = a.addDir("", dir); err != nil {
+			return err
+		}
+		added = append(added, &namedNode{"", root})
+	} else {
+		it := dir.Entries()
+		for it.Next() {
+			root, err = a.addNode(it.Name(), it.Node())
+			if err != nil {
+				return err
+			}
+			added = append(added, &namedNode{it.Name(), root})
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+	}
+	if root == nil {
+		return clientError("file argument was not provided")
+	}
+
+	for _, ev := range a.dirs {
+		if err := res.emit(ev); err != nil {
+			return err
+		}
+	}
+
+	if pin && !onlyHash {
+		s.pins[root.Cid()] = "recursive"
+	}
+	if toFilesSet {
+		return s.addToFiles(toFiles, added)
+	}
+	return nil
+}
+
+type namedNode struct {
+	name string
+	nd   ipld.Node
+}
+
+// addToFiles links the added nodes into the MFS, like add --to-files.
+func (s *Server) addToFiles(dst string, added []*namedNode) error {
+	if dst == "" {
+		dst = "/"
+	}
+	dst, err := checkPath(dst)
+	if err != nil {
+		return fmt.Errorf("to-files: %w", err)
+	}
+	dstAsDir := dst[len(dst)-1] == '/'
+
+	for i, n := range added {
+		target := dst
+		if dstAsDir {
+			fsn, err := mfs.Lookup(s.files, dst)
+			if err != nil {
+				return fmt.Errorf("to-files: MFS destination directory %q does not exist: %w", dst, err)
+			}
+			if fsn.Type() != mfs.TDir {
+				return fmt.Errorf("to-files: MFS destination %q is not a directory", dst)
+			}
+			target += gopath.Base(n.name)
+		} else if i > 0 {
+			return fmt.Errorf("to-files: MFS destination is a file: only one entry can be copied to %q", dst)
+		}
+
+		if _, err := mfs.Lookup(s.files, gopath.Dir(target)); err != nil {
+			return fmt.Errorf("to-files: MFS destination parent %q %q does not exist: %w", target, gopath.Dir(target), err)
+		}
+		if err := mfs.PutNode(s.files, target, n.nd); err != nil {
+			return fmt.Errorf("to-files: cannot put node in path %q: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func (a *adder) addNode(name string, n files.Node) (ipld.Node, error) {
+	var (
+		nd  ipld.Node
+		err error
+	)
+	switch n := n.(type) {
+	case files.Directory:
+		return a.addDir(name, n)
+	case *files.Symlink:
+		var data []byte
+		data, err = ft.SymlinkData(n.Target)
+		if err != nil {
+			return nil, err
+		}
+		pn := dag.NodeWithData(data)
+		pn.SetCidBuilder(a.prefix)
+		nd = pn
+		err = a.dag.Add(a.ctx, nd)
+	case files.File:
+		nd, err = a.addFile(name, n)
+	default:
+		return nil, fmt.Errorf("unrecognized file type %T", n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nd, a.res.emit(a.event(name, nd))
+}
+
+func (a *adder) addFile(name string, f files.File) (ipld.Node, error) {
+	var r io.Reader = f
+	if a.progress {
+		r = &progressReader{r: f, name: name, res: a.res}
+	}
+
+	chnk, err := chunker.FromString(r, a.chunker)
+	if err != nil {
+		return nil, clientError(err.Error())
+	}
+
+	params := ihelper.DagBuilderParams{
+		Dagserv:    a.dag,
+		RawLeaves:  a.rawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: a.prefix,
+	}
+	db, err := params.New(chnk)
+	if err != nil {
+		return nil, err
+	}
+	if a.trickle {
+		return trickle.Layout(db)
+	}
+	return balanced.Layout(db)
+}
+
+func (a *adder) addDir(name string, d files.Directory) (ipld.Node, error) {
+	dir := uio.NewDirectory(a.dag)
+	dir.SetCidBuilder(a.prefix)
+
+	it := d.Entries()
+	for it.Next() {
+		child, err := a.addNode(gopath.Join(name, it.Name()), it.Node())
+		if err != nil {
+			return nil, err
+		}
+		// Like the daemon, link unnamed files by their CID.
+		linkName := it.Name()
+		if linkName == "" {
+			linkName = child.Cid().String()
+		}
+		if err := dir.AddChild(a.ctx, linkName, child); err != nil {
+			return nil, err
+		}
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.dag.Add(a.ctx, nd); err != nil {
+		return nil, err
+	}
+
+	// Like the daemon, report directories after all of the files.
+	a.dirs = append(a.dirs, a.event(name, nd))
+	return nd, nil
+}
+
+func (a *adder) event(name string, nd ipld.Node) addEvent {
+	size, _ := nd.Size()
+	if name == "" {
+		name = nd.Cid().String()
+	}
+	return addEvent{
+		Name: name,
+		Hash: nd.Cid().String(),
+		Size: strconv.FormatUint(size, 10),
+	}
+}
+
+// progressReader emits Bytes events while a file is being read.
+type progressReader struct {
+	r     io.Reader
+	name  string
+	res   *response
+	bytes int64
+	last  int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.bytes += int64(n)
+	if p.bytes-p.last >= progressIncrement || (err == io.EOF && p.bytes != p.last) {
+		p.last = p.bytes
+		if perr := p.res.emit(addEvent{Name: p.name, Bytes: p.bytes}); perr != nil {
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+func (s *Server) cat(req *request, res *response) error {
+	p, err := req.arg(0, "ipfs-path")
+	if err != nil {
+		return err
+	}
+	offset, err := req.intOption("offset", 0)
+	if err != nil {
+		return err
+	}
+	length, err := req.intOption("length", -1)
+	if err != nil {
+		return err
+	}
+
+	nd, err := s.resolve(req.Context(), p)
+	if err != nil {
+		return err
+	}
+	dr, err := uio.NewDagReader(req.Context(), nd, s.dag)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	if _, err := dr.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = dr
+	if length >= 0 {
+		r = io.LimitReader(r, length)
+	}
+	return res.stream(r)
+}
+
+type lsLink struct {
+	Name, Hash string
+	Size       uint64
+	Type       unixfs_pb.Data_DataType
+	Target     string
+}
+
+type lsObject struct {
+	Hash  string
+	Links []lsLink
+}
+
+func (s *Server) ls(req *request, res *response) error {
+	if len(req.args) == 0 {
+		return clientError("argument %q is required", "ipfs-path")
+	}
+
+	var out struct{ Objects []lsObject }
+	for _, p := range req.args {
+		nd, err := s.resolve(req.Context(), p)
+		if err != nil {
+			return err
+		}
+
+		obj := lsObject{Hash: p, Links: []lsLink{}}
+		var links []*ipld.Link
+		if dir, err := uio.NewDirectoryFromNode(s.dag, nd); err == nil {
+			if links, err = dir.Links(req.Context()); err != nil {
+				return err
+			}
+		}
+		for _, l := range links {
+			link := lsLink{Name: l.Name, Hash: l.Cid.String()}
+			child, err := s.dag.Get(req.Context(), l.Cid)
+			if err != nil {
+				return err
+			}
+			link.Type, link.Size, link.Target = unixfsInfo(child)
+			obj.Links = append(obj.Links, link)
+		}
+		out.Objects = append(out.Objects, obj)
+	}
+
+	return res.emit(out)
+}
+
+// unixfsInfo returns the UnixFS type, file size and symlink target of a
+// node.
+func unixfsInfo(nd ipld.Node) (unixfs_pb.Data_DataType, uint64, string) {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return unixfs_pb.Data_File, uint64(len(nd.RawData())), ""
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return -1, 0, ""
+		}
+		switch fsn.Type() {
+		case unixfs_pb.Data_File, unixfs_pb.Data_Raw:
+			return unixfs_pb.Data_File, fsn.FileSize(), ""
+		case unixfs_pb.Data_Symlink:
+			return unixfs_pb.Data_Symlink, 0, string(fsn.Data())
+		case unixfs_pb.Data_HAMTShard:
+			return unixfs_pb.Data_Directory, 0, ""
+		default:
+			return fsn.Type(), 0, ""
+		}
+	default:
+		return -1, 0, ""
+	}
+}
@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"crypto/tls"
+	gohttp "net/http"
+	"time"
+)
+
+// Connection pool defaults of the transport created by NewShell. A shell
+// talks to a single daemon, so most of the pool goes to that one host.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 64
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// newTransport returns the transport used by NewShell: connections to the
+// daemon are kept alive and reused, and HTTP/2 is negotiated with daemons
+// served over TLS.
+func newTransport() *gohttp.Transport {
+	return &gohttp.Transport{
+		Proxy:                 gohttp.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          DefaultMaxIdleConns,
+		MaxIdleConnsPerHost:   DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       DefaultIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// configureTransport applies f to a copy of the shell's transport, so that
+// transports shared with other clients are left alone. It does nothing when
+// the client was given a custom http.RoundTripper.
+func (s *Shell) configureTransport(f func(*gohttp.Transport)) {
+	var tpt *gohttp.Transport
+	switch t := s.httpcli.Transport.(type) {
+	case nil:
+		tpt = gohttp.DefaultTransport.(*gohttp.Transport).Clone()
+	case *gohttp.Transport:
+		tpt = t.Clone()
+	default:
+		return
+	}
+	f(tpt)
+	s.httpcli.Transport = tpt
+}
+
+// WithKeepAlives sets whether connections to the daemon are reused. They are
+// by default. Disabling keep-alives opens a new connection for every request.
+func WithKeepAlives(enabled bool) ShellOption {
+	return func(s *Shell) {
+		s.configureTransport(func(t *gohttp.Transport) {
+			t.DisableKeepAlives = !enabled
+		})
+	}
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections to the daemon are
+// kept for reuse. Default DefaultMaxIdleConnsPerHost.
+func WithMaxIdleConnsPerHost(n int) ShellOption {
+	return func(s *Shell) {
+		s.configureTransport(func(t *gohttp.Transport) {
+			t.MaxIdleConnsPerHost = n
+			if t.MaxIdleConns != 0 && t.MaxIdleConns < n {
+				t.MaxIdleConns = n
+			}
+		})
+	}
+}
+
+// WithMaxConnsPerHost limits the number of connections to the daemon, idle
+// or not. Requests beyond the limit wait for a connection. Zero, the default,
+// means no limit.
+func WithMaxConnsPerHost(n int) ShellOption {
+	return func(s *Shell) {
+		s.configureTransport(func(t *gohttp.Transport) {
+			t.MaxConnsPerHost = n
+		})
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept before being
+// closed. Default DefaultIdleConnTimeout.
+func WithIdleConnTimeout(d time.Duration) ShellOption {
+	return func(s *Shell) {
+		s.configureTransport(func(t *gohttp.Transport) {
+			t.IdleConnTimeout = d
+		})
+	}
+}
+
+// WithHTTP2 sets whether HTTP/2 is negotiated with daemons served over TLS.
+// It is by default. Plain HTTP connections always use HTTP/1.1.
+func WithHTTP2(enabled bool) ShellOption {
+	return func(s *Shell) {
+		s.configureTransport(func(t *gohttp.Transport) {
+			t.ForceAttemptHTTP2 = enabled
+			if !enabled {
+				// a non-nil empty map disables HTTP/2
+				t.TLSNextProto = map[string]func(string, *tls.Conn) gohttp.RoundTripper{}
+			}
+		})
+	}
+}
+
+// CloseIdleConnections closes the idle connections to the daemon kept for
+// reuse. It doesn't interrupt requests in progress.
+func (s *Shell) CloseIdleConnections() {
+	s.httpcli.CloseIdleConnections()
+}
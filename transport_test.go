@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cheekybits/is"
+)
+
+// blockStatServer stands in for the daemon, answering every request like
+// block/stat, and counts the connections it accepts.
+func blockStatServer(tb testing.TB) (*httptest.Server, *int64) {
+	var conns int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"Key":%q,"Size":42}`, r.URL.Query().Get("arg"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	srv.Start()
+	tb.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+func TestKeepAlives(t *testing.T) {
+	is := is.New(t)
+
+	for _, tc := range []struct {
+		opts  []ShellOption
+		conns int64
+	}{
+		{nil, 1},
+		{[]ShellOption{WithKeepAlives(false)}, 10},
+		{[]ShellOption{WithMaxIdleConnsPerHost(0), WithIdleConnTimeout(0)}, 1},
+	} {
+		srv, conns := blockStatServer(t)
+		s := NewShell(srv.URL, tc.opts...)
+		for i := 0; i < 10; i++ {
+			key, size, err := s.BlockStat("block")
+			is.Nil(err)
+			is.Equal(key, "block")
+			is.Equal(size, 42)
+		}
+		is.Equal(atomic.LoadInt64(conns), tc.conns)
+	}
+
+	// options don't change the transport given by the caller
+	tpt := &http.Transport{}
+	NewShellWithClient("localhost:5001", &http.Client{Transport: tpt}, WithKeepAlives(false), WithMaxConnsPerHost(2))
+	is.False(tpt.DisableKeepAlives)
+	is.Equal(tpt.MaxConnsPerHost, 0)
+}
+
+func BenchmarkSmallRPCs(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []ShellOption
+	}{
+		{"keep-alive", nil},
+		{"no-keep-alive", []ShellOption{WithKeepAlives(false)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			srv, conns := blockStatServer(b)
+			s := NewShell(srv.URL, bc.opts...)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, _, err := s.BlockStat("block"); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+			s.CloseIdleConnections()
+			b.ReportMetric(float64(atomic.LoadInt64(conns))/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -25,8 +25,13 @@ type lsOutput struct {
 
 // FileList entries at the given path using the UnixFS commands
 func (s *Shell) FileList(path string) (*UnixLsObject, error) {
+	return s.FileListCtx(context.Background(), path)
+}
+
+// FileListCtx is like FileList but with a context.
+func (s *Shell) FileListCtx(ctx context.Context, path string) (*UnixLsObject, error) {
 	var out lsOutput
-	if err := s.Request("file/ls", path).Exec(context.Background(), &out); err != nil {
+	if err := s.Request("file/ls", path).Exec(ctx, &out); err != nil {
 		return nil, err
 	}
 